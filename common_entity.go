@@ -10,24 +10,60 @@ var (
 	ErrInvalidValue = errors.New("Invalid values!")
 )
 
+// EntityOption 创建实体时struct类型数据的解析选项
+type EntityOption utils.DecodeOption
+
+// IncludeZero 保留struct类型数据中的零值字段
+// 未指定fields时保留所有字段，否则只保留指定字段(列名或字段名称)；标记为omitempty的字段仍然被忽略
+// 例如：NewTranUEntity("student", Student{Age: 0, Sex: 0}, cond, IncludeZero("Age", "Sex"))
+func IncludeZero(fields ...string) EntityOption {
+	return EntityOption(utils.IncludeZero(fields...))
+}
+
+func decodeFields(value interface{}, opts []EntityOption, extra ...utils.DecodeOption) (fields map[string]interface{}, err error) {
+	err = newDecoder(value, opts, extra...).Decode(&fields)
+	return
+}
+
+func newDecoder(value interface{}, opts []EntityOption, extra ...utils.DecodeOption) utils.Decoder {
+	decodeOpts := extra
+	for _, opt := range opts {
+		decodeOpts = append(decodeOpts, utils.DecodeOption(opt))
+	}
+	return utils.NewDecoder(value, decodeOpts...)
+}
+
+// SQLExpr SQL表达式，作为值使用时原样写入SQL语句
+type SQLExpr struct {
+	SQL    string
+	Values []interface{}
+}
+
+// Expr 创建SQL表达式
+// 例如：Expr("Count+?", 1)、Expr("VALUES(Memo)")
+func Expr(sql string, values ...interface{}) SQLExpr {
+	return SQLExpr{SQL: sql, Values: values}
+}
+
 // CondType 查询条件类型标识
 type CondType byte
 
 const (
 	COND_KV CondType = iota + 1
 	COND_CV
+	COND_EXPR
 )
 
 // NewFieldsKvCondition 获取键值查询条件实例
 // fieldsKv 数据类型(map[string]interface{} or map[string]string or struct)
-// 如果fieldsKv为struct类型，只保留非零值字段
-func NewFieldsKvCondition(fieldsKv interface{}) QueryConditionResult {
+// 如果fieldsKv为struct类型，只保留非零值字段（nil指针字段同样被忽略，可使用IncludeZero保留）
+// 值为nil时使用IS NULL匹配
+func NewFieldsKvCondition(fieldsKv interface{}, opts ...EntityOption) QueryConditionResult {
 	var (
 		result QueryConditionResult
 		queryC QueryCondition
-		fields map[string]interface{}
 	)
-	err := utils.NewDecoder(fieldsKv).Decode(&fields)
+	fields, err := decodeFields(fieldsKv, opts)
 	if err != nil {
 		result.Error = err
 		return result
@@ -57,4 +93,5 @@ type QueryCondition struct {
 	FieldsKv  map[string]interface{}
 	Condition string
 	Values    []interface{}
+	Expr      CondExpr
 }
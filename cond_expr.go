@@ -0,0 +1,144 @@
+package dal
+
+// CondOp 条件表达式运算符
+type CondOp byte
+
+const (
+	// OpEq 等于
+	OpEq CondOp = iota + 1
+	// OpNe 不等于
+	OpNe
+	// OpGt 大于
+	OpGt
+	// OpGe 大于等于
+	OpGe
+	// OpLt 小于
+	OpLt
+	// OpLe 小于等于
+	OpLe
+	// OpIn 包含
+	OpIn
+	// OpNotIn 不包含
+	OpNotIn
+	// OpLike 模糊匹配
+	OpLike
+	// OpNotLike 模糊不匹配
+	OpNotLike
+	// OpBetween 区间
+	OpBetween
+	// OpIsNull 为空
+	OpIsNull
+	// OpIsNotNull 不为空
+	OpIsNotNull
+	// OpAnd 与
+	OpAnd
+	// OpOr 或
+	OpOr
+	// OpNot 非
+	OpNot
+)
+
+// CondExpr 条件表达式
+// 比较类运算使用Field与Values，逻辑运算(OpAnd,OpOr,OpNot)使用Exprs
+type CondExpr struct {
+	Op     CondOp
+	Field  string
+	Values []interface{}
+	Exprs  []CondExpr
+}
+
+// NewExprCondition 获取条件表达式查询条件
+func NewExprCondition(expr CondExpr) QueryConditionResult {
+	var result QueryConditionResult
+	result.Condition = QueryCondition{
+		CType: COND_EXPR,
+		Expr:  expr,
+	}
+	return result
+}
+
+// Where 获取多个条件表达式以AND连接的查询条件
+func Where(exprs ...CondExpr) QueryConditionResult {
+	return NewExprCondition(And(exprs...))
+}
+
+// Eq field = value（value为nil时等同于IsNull）
+func Eq(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpEq, Field: field, Values: []interface{}{value}}
+}
+
+// Ne field <> value（value为nil时等同于IsNotNull）
+func Ne(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpNe, Field: field, Values: []interface{}{value}}
+}
+
+// Gt field > value
+func Gt(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpGt, Field: field, Values: []interface{}{value}}
+}
+
+// Ge field >= value
+func Ge(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpGe, Field: field, Values: []interface{}{value}}
+}
+
+// Lt field < value
+func Lt(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpLt, Field: field, Values: []interface{}{value}}
+}
+
+// Le field <= value
+func Le(field string, value interface{}) CondExpr {
+	return CondExpr{Op: OpLe, Field: field, Values: []interface{}{value}}
+}
+
+// In field IN (values...)
+// 如果只提供一个切片类型的参数，则使用切片的元素
+func In(field string, values ...interface{}) CondExpr {
+	return CondExpr{Op: OpIn, Field: field, Values: values}
+}
+
+// NotIn field NOT IN (values...)
+func NotIn(field string, values ...interface{}) CondExpr {
+	return CondExpr{Op: OpNotIn, Field: field, Values: values}
+}
+
+// Like field LIKE pattern
+func Like(field string, pattern string) CondExpr {
+	return CondExpr{Op: OpLike, Field: field, Values: []interface{}{pattern}}
+}
+
+// NotLike field NOT LIKE pattern
+func NotLike(field string, pattern string) CondExpr {
+	return CondExpr{Op: OpNotLike, Field: field, Values: []interface{}{pattern}}
+}
+
+// Between field BETWEEN begin AND end
+func Between(field string, begin, end interface{}) CondExpr {
+	return CondExpr{Op: OpBetween, Field: field, Values: []interface{}{begin, end}}
+}
+
+// IsNull field IS NULL
+func IsNull(field string) CondExpr {
+	return CondExpr{Op: OpIsNull, Field: field}
+}
+
+// IsNotNull field IS NOT NULL
+func IsNotNull(field string) CondExpr {
+	return CondExpr{Op: OpIsNotNull, Field: field}
+}
+
+// And 多个表达式同时成立
+func And(exprs ...CondExpr) CondExpr {
+	return CondExpr{Op: OpAnd, Exprs: exprs}
+}
+
+// Or 多个表达式任一成立
+func Or(exprs ...CondExpr) CondExpr {
+	return CondExpr{Op: OpOr, Exprs: exprs}
+}
+
+// Not 表达式不成立
+func Not(expr CondExpr) CondExpr {
+	return CondExpr{Op: OpNot, Exprs: []CondExpr{expr}}
+}
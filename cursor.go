@@ -0,0 +1,221 @@
+package dal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor 无效的游标
+var ErrInvalidCursor = errors.New("Invalid cursor!")
+
+// CursorParam 游标分页参数
+type CursorParam struct {
+	// Keys 排序键，必须能够唯一确定行的顺序(例如以主键结尾)，且排序方向一致
+	Keys []OrderField
+	// Cursor 游标，首页为空，之后使用返回结果中的NextCursor或PrevCursor
+	Cursor string
+	// Size 每页数量
+	Size int
+}
+
+// PageSize 每页数量，未设置时为15
+func (p CursorParam) PageSize() int {
+	if p.Size <= 0 {
+		return 15
+	}
+	return p.Size
+}
+
+// NewCursorParam 创建新的游标分页参数
+func NewCursorParam(cursor string, size int, keys ...OrderField) CursorParam {
+	if size <= 0 {
+		size = 15
+	}
+	return CursorParam{
+		Keys:   keys,
+		Cursor: cursor,
+		Size:   size,
+	}
+}
+
+// NewQueryCursorEntity 创建新的游标分页查询实体
+func NewQueryCursorEntity(table string, cond QueryCondition, cursorParam CursorParam, fields ...string) QueryEntityResult {
+	var result QueryEntityResult
+	result.Entity = QueryEntity{
+		Table:        table,
+		FieldsSelect: strings.Join(fields, ","),
+		Condition:    cond,
+		ResultType:   QCursor,
+		CursorParam:  cursorParam,
+	}
+	return result
+}
+
+type cursorToken struct {
+	Values   []interface{} `json:"v"`
+	Backward bool          `json:"b,omitempty"`
+}
+
+// cursorValue 游标中带类型标记的值，解析后还原为编码前的类型
+// 时间使用TimeFormat格式(UTC)，[]byte使用base64编码，数值使用字符串避免精度丢失
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+func encodeCursorValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return cursorValue{"time", v.UTC().Format(TimeFormat)}
+	case []byte:
+		return cursorValue{"bytes", base64.StdEncoding.EncodeToString(v)}
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cursorValue{"int", strconv.FormatInt(rv.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{"uint", strconv.FormatUint(rv.Uint(), 10)}
+	case reflect.Float32, reflect.Float64:
+		return cursorValue{"float", strconv.FormatFloat(rv.Float(), 'g', -1, 64)}
+	}
+	return value
+}
+
+func decodeCursorValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		// 没有类型标记的数值
+		if iv, err := v.Int64(); err == nil {
+			return iv, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		t, _ := v["t"].(string)
+		s, _ := v["v"].(string)
+		switch t {
+		case "time":
+			return time.ParseInLocation(TimeFormat, s, time.UTC)
+		case "bytes":
+			return base64.StdEncoding.DecodeString(s)
+		case "int":
+			return strconv.ParseInt(s, 10, 64)
+		case "uint":
+			return strconv.ParseUint(s, 10, 64)
+		case "float":
+			return strconv.ParseFloat(s, 64)
+		}
+		return nil, ErrInvalidCursor
+	}
+	return value, nil
+}
+
+// EncodeCursor 将排序键的值编码为游标，值的类型在解析时还原
+// backward 为true时表示向前翻页
+func EncodeCursor(values []interface{}, backward bool) string {
+	tagged := make([]interface{}, len(values))
+	for i, v := range values {
+		tagged[i] = encodeCursorValue(v)
+	}
+	buf, err := json.Marshal(cursorToken{Values: tagged, Backward: backward})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeCursor 解析游标，返回排序键的值及翻页方向
+func DecodeCursor(cursor string) (values []interface{}, backward bool, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		err = ErrInvalidCursor
+		return
+	}
+	var token cursorToken
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err = decoder.Decode(&token); err != nil || len(token.Values) == 0 {
+		err = ErrInvalidCursor
+		return
+	}
+	values = make([]interface{}, len(token.Values))
+	for i, v := range token.Values {
+		if values[i], err = decodeCursorValue(v); err != nil {
+			values, err = nil, ErrInvalidCursor
+			return
+		}
+	}
+	backward = token.Backward
+	return
+}
+
+// ColumnName 获取字段在查询结果中的列名，去除表名限定及反引号(例如：`s`.`StuCode` => StuCode)
+func ColumnName(field string) string {
+	if idx := strings.LastIndex(field, "."); idx > -1 {
+		field = field[idx+1:]
+	}
+	if l := len(field); l > 2 && field[0] == '`' && field[l-1] == '`' {
+		field = strings.Replace(field[1:l-1], "``", "`", -1)
+	}
+	return field
+}
+
+// CursorValues 获取行数据中排序键的值，列名不区分大小写
+func CursorValues(row map[string]interface{}, keys []OrderField) ([]interface{}, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		column := ColumnName(key.Field)
+		value, ok := row[column]
+		if !ok {
+			for k, v := range row {
+				if strings.EqualFold(k, column) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("Cursor key `%s` is not selected", key.Field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// NewCursorResult 使用当前页的数据创建游标分页结果
+// rows为按照排序键顺序排列的当前页数据，backward为翻页方向，hasMore表示翻页方向上是否还有数据
+func NewCursorResult(rows []map[string]interface{}, param CursorParam, backward, hasMore bool) (result QueryCursorResult, err error) {
+	result.Rows = rows
+	if len(rows) == 0 {
+		return
+	}
+	first, err := CursorValues(rows[0], param.Keys)
+	if err != nil {
+		return
+	}
+	last, err := CursorValues(rows[len(rows)-1], param.Keys)
+	if err != nil {
+		return
+	}
+	if backward {
+		if hasMore {
+			result.PrevCursor = EncodeCursor(first, true)
+		}
+		result.NextCursor = EncodeCursor(last, false)
+	} else {
+		if hasMore {
+			result.NextCursor = EncodeCursor(last, false)
+		}
+		if param.Cursor != "" {
+			result.PrevCursor = EncodeCursor(first, true)
+		}
+	}
+	return
+}
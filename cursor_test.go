@@ -0,0 +1,49 @@
+package dal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	values := []interface{}{
+		time.Date(2016, 10, 13, 8, 30, 0, 123456000, time.UTC),
+		[]byte{0xff, 0x01},
+		uint64(18446744073709551615),
+		int64(-9007199254740993),
+		1.5,
+		"S001",
+		nil,
+	}
+	cursor := EncodeCursor(values, true)
+	decoded, backward, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !backward {
+		t.Error("expected backward cursor")
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Errorf("unexpected values: %#v", decoded)
+	}
+}
+
+func TestCursorTimeUTC(t *testing.T) {
+	local := time.Date(2016, 10, 13, 16, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	values, _, err := DecodeCursor(EncodeCursor([]interface{}{local}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := values[0].(time.Time); !ok || !v.Equal(local) {
+		t.Errorf("unexpected time: %#v", values[0])
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"", "!", "eyJ2IjpbXX0", "eyJ2IjpbeyJ0IjoieCIsInYiOiIxIn1dfQ"} {
+		if _, _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("%q: expected ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package dal
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
 )
 
 // QueryProvider 提供数据库查询接口
@@ -29,6 +34,39 @@ type QueryProvider interface {
 	Pager(entity QueryEntity) (QueryPagerResult, error)
 	// 查询数据（根据QueryResultType返回数据结果类型）
 	Query(entity QueryEntity) (interface{}, error)
+
+	// SingleContext 查询单条数据（支持上下文）
+	SingleContext(ctx context.Context, entity QueryEntity) (map[string]string, error)
+	// SingleWithSQLContext 查询单条数据（支持上下文）
+	SingleWithSQLContext(ctx context.Context, sql string, values ...interface{}) (map[string]string, error)
+	// AssignSingleContext 将查询结果解析到对应的指针地址（支持上下文）
+	AssignSingleContext(ctx context.Context, entity QueryEntity, output interface{}) error
+	// AssignSingleWithSQLContext 将查询结果解析到对应的指针地址（支持上下文）
+	AssignSingleWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error
+	// ListContext 查询列表数据（支持上下文）
+	ListContext(ctx context.Context, entity QueryEntity) ([]map[string]string, error)
+	// ListWithSQLContext 使用sql查询数据列表（支持上下文）
+	ListWithSQLContext(ctx context.Context, sql string, values ...interface{}) ([]map[string]string, error)
+	// AssignListContext 将查询结果解析到对应的指针地址（支持上下文）
+	AssignListContext(ctx context.Context, entity QueryEntity, output interface{}) error
+	// AssignListWithSQLContext 使用sql查询数据列表（支持上下文）
+	AssignListWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error
+	// PagerContext 查询分页数据（支持上下文）
+	PagerContext(ctx context.Context, entity QueryEntity) (QueryPagerResult, error)
+	// QueryContext 查询数据（支持上下文）
+	QueryContext(ctx context.Context, entity QueryEntity) (interface{}, error)
+	// Cursor 查询游标分页数据
+	Cursor(entity QueryEntity) (QueryCursorResult, error)
+	// CursorContext 查询游标分页数据（支持上下文）
+	CursorContext(ctx context.Context, entity QueryEntity) (QueryCursorResult, error)
+	// QueryRows 查询数据并逐行读取
+	QueryRows(entity QueryEntity) (Rows, error)
+	// QueryRowsContext 查询数据并逐行读取（支持上下文）
+	QueryRowsContext(ctx context.Context, entity QueryEntity) (Rows, error)
+	// QueryRowsWithSQL 使用sql查询数据并逐行读取
+	QueryRowsWithSQL(sql string, values ...interface{}) (Rows, error)
+	// QueryRowsWithSQLContext 使用sql查询数据并逐行读取（支持上下文）
+	QueryRowsWithSQLContext(ctx context.Context, sql string, values ...interface{}) (Rows, error)
 }
 
 // TranProvider 提供数据库事务操作
@@ -37,12 +75,38 @@ type TranProvider interface {
 	Exec(TranEntity) TranResult
 	// ExecTrans 执行多条事务性操作
 	ExecTrans([]TranEntity) TranResult
+	// ExecContext 执行单条事务性操作（支持上下文）
+	ExecContext(context.Context, TranEntity) TranResult
+	// ExecTransContext 执行多条事务性操作（支持上下文）
+	ExecTransContext(context.Context, []TranEntity) TranResult
+	// ExecWithSQL 执行sql语句
+	ExecWithSQL(sql string, values ...interface{}) TranResult
+	// ExecWithSQLContext 执行sql语句（支持上下文）
+	ExecWithSQLContext(ctx context.Context, sql string, values ...interface{}) TranResult
+}
+
+// TxProvider 提供显式事务操作
+type TxProvider interface {
+	// Begin 开启事务（在事务中调用时开启嵌套事务）
+	Begin() (Tx, error)
+	// BeginContext 开启事务（支持上下文）
+	BeginContext(ctx context.Context) (Tx, error)
 }
 
 // Provider 提供统一的数据库操作
 type Provider interface {
 	QueryProvider
 	TranProvider
+	TxProvider
+}
+
+// Tx 提供事务内的数据库操作
+type Tx interface {
+	Provider
+	// Commit 提交事务
+	Commit() error
+	// Rollback 回滚事务
+	Rollback() error
 }
 
 // DBProvider 提供DB初始化
@@ -61,43 +125,117 @@ const (
 	MYSQL ProvideEngine = "mysql"
 )
 
+// DefaultName 默认Provider实例名称
+const DefaultName = "default"
+
 var (
-	// GDAL 提供全局的Provider
+	// GDAL 提供全局的Provider（默认实例）
 	GDAL      Provider
-	providers map[ProvideEngine]DBProvider
+	providers map[ProvideEngine]func() DBProvider
+	instances map[string]Provider
+	mux       sync.RWMutex
 )
 
 func init() {
-	providers = make(map[ProvideEngine]DBProvider)
+	providers = make(map[ProvideEngine]func() DBProvider)
+	instances = make(map[string]Provider)
 }
 
 // RegisterDBProvider 注册DBProvider
+// 每个命名实例使用与provider相同类型的新实例(provider为指针时)，否则共用provider
 func RegisterDBProvider(provideName ProvideEngine, provider DBProvider) {
 	if provider == nil {
 		panic("go-dal:DBProvider is nil!")
 	}
+	RegisterDBProviderFactory(provideName, func() DBProvider {
+		if t := reflect.TypeOf(provider); t.Kind() == reflect.Ptr {
+			return reflect.New(t.Elem()).Interface().(DBProvider)
+		}
+		return provider
+	})
+}
+
+// RegisterDBProviderFactory 注册创建DBProvider的函数
+// creator 用于创建DBProvider实例，每个命名实例都会调用一次
+func RegisterDBProviderFactory(provideName ProvideEngine, creator func() DBProvider) {
+	if creator == nil {
+		panic("go-dal:DBProvider is nil!")
+	}
+	mux.Lock()
+	defer mux.Unlock()
 	if _, ok := providers[provideName]; ok {
 		panic("go-dal:DBProvider has been registered!")
 	}
-	providers[provideName] = provider
+	providers[provideName] = creator
 }
 
 // RegisterProvider 提供全局的provider
 func RegisterProvider(provideName ProvideEngine, config string) error {
-	if GDAL != nil {
+	return RegisterNamedProvider(DefaultName, provideName, config)
+}
+
+// RegisterNamedProvider 注册指定名称的Provider实例
+// 每个实例独立维护数据库连接，通过Use(name)获取
+func RegisterNamedProvider(name string, provideName ProvideEngine, config string) error {
+	mux.RLock()
+	_, exists := instances[name]
+	creator, ok := providers[provideName]
+	mux.RUnlock()
+	if exists {
 		return errors.New("Provider has been registered!")
 	}
-	provide, ok := providers[provideName]
 	if !ok {
 		return errors.New("Unknown provider!")
 	}
+	// 初始化(连接数据库)时不持有锁，避免阻塞其他实例的注册及Use
+	provide := creator()
 	if err := provide.InitDB(config); err != nil {
 		return err
 	}
-	GDAL = provide
+	mux.Lock()
+	defer mux.Unlock()
+	if _, ok := instances[name]; ok {
+		// 初始化期间同名实例已注册，关闭当前实例的连接
+		if c, ok := provide.(io.Closer); ok {
+			c.Close()
+		}
+		return errors.New("Provider has been registered!")
+	}
+	instances[name] = provide
+	if name == DefaultName {
+		GDAL = provide
+	}
+	return nil
+}
+
+// RegisterInstance 注册已创建的Provider实例(例如分片Provider)，通过Use(name)获取
+func RegisterInstance(name string, provide Provider) error {
+	if provide == nil {
+		return errors.New("Provider is nil!")
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if _, ok := instances[name]; ok {
+		return errors.New("Provider has been registered!")
+	}
+	instances[name] = provide
+	if name == DefaultName {
+		GDAL = provide
+	}
 	return nil
 }
 
+// Use 获取指定名称的Provider实例
+func Use(name string) Provider {
+	mux.RLock()
+	provide, ok := instances[name]
+	mux.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("go-dal:Provider `%s` is not registered!", name))
+	}
+	return provide
+}
+
 // Single 查询单条数据
 func Single(entity QueryEntity) (map[string]string, error) {
 	return GDAL.Single(entity)
@@ -147,6 +285,21 @@ func Pager(entity QueryEntity) (QueryPagerResult, error) {
 	return GDAL.Pager(entity)
 }
 
+// Cursor 查询游标分页数据
+func Cursor(entity QueryEntity) (QueryCursorResult, error) {
+	return GDAL.Cursor(entity)
+}
+
+// QueryRows 查询数据并逐行读取（使用完毕后需要调用Close）
+func QueryRows(entity QueryEntity) (Rows, error) {
+	return GDAL.QueryRows(entity)
+}
+
+// QueryRowsWithSQL 使用sql查询数据并逐行读取（使用完毕后需要调用Close）
+func QueryRowsWithSQL(sql string, values ...interface{}) (Rows, error) {
+	return GDAL.QueryRowsWithSQL(sql, values...)
+}
+
 // Query 查询数据
 //（根据QueryResultType返回数据结果类型）
 func Query(entity QueryEntity) (interface{}, error) {
@@ -162,3 +315,129 @@ func Exec(entity TranEntity) TranResult {
 func ExecTrans(entities []TranEntity) TranResult {
 	return GDAL.ExecTrans(entities)
 }
+
+// ExecWithSQL 执行sql语句
+func ExecWithSQL(sql string, values ...interface{}) TranResult {
+	return GDAL.ExecWithSQL(sql, values...)
+}
+
+// SingleContext 查询单条数据（支持上下文）
+func SingleContext(ctx context.Context, entity QueryEntity) (map[string]string, error) {
+	return GDAL.SingleContext(ctx, entity)
+}
+
+// SingleWithSQLContext 查询单条数据（支持上下文）
+func SingleWithSQLContext(ctx context.Context, sql string, values ...interface{}) (map[string]string, error) {
+	return GDAL.SingleWithSQLContext(ctx, sql, values...)
+}
+
+// AssignSingleContext 将查询结果解析到对应的指针地址（支持上下文）
+func AssignSingleContext(ctx context.Context, entity QueryEntity, output interface{}) error {
+	return GDAL.AssignSingleContext(ctx, entity, output)
+}
+
+// AssignSingleWithSQLContext 将查询结果解析到对应的指针地址（支持上下文）
+func AssignSingleWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	return GDAL.AssignSingleWithSQLContext(ctx, sql, values, output)
+}
+
+// ListContext 查询列表数据（支持上下文）
+func ListContext(ctx context.Context, entity QueryEntity) ([]map[string]string, error) {
+	return GDAL.ListContext(ctx, entity)
+}
+
+// ListWithSQLContext 查询列表数据（支持上下文）
+func ListWithSQLContext(ctx context.Context, sql string, values ...interface{}) ([]map[string]string, error) {
+	return GDAL.ListWithSQLContext(ctx, sql, values...)
+}
+
+// AssignListContext 将查询结果解析到对应的指针地址（支持上下文）
+func AssignListContext(ctx context.Context, entity QueryEntity, output interface{}) error {
+	return GDAL.AssignListContext(ctx, entity, output)
+}
+
+// AssignListWithSQLContext 将查询结果解析到对应的指针地址（支持上下文）
+func AssignListWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	return GDAL.AssignListWithSQLContext(ctx, sql, values, output)
+}
+
+// PagerContext 查询分页数据（支持上下文）
+func PagerContext(ctx context.Context, entity QueryEntity) (QueryPagerResult, error) {
+	return GDAL.PagerContext(ctx, entity)
+}
+
+// CursorContext 查询游标分页数据（支持上下文）
+func CursorContext(ctx context.Context, entity QueryEntity) (QueryCursorResult, error) {
+	return GDAL.CursorContext(ctx, entity)
+}
+
+// QueryRowsContext 查询数据并逐行读取（支持上下文）
+func QueryRowsContext(ctx context.Context, entity QueryEntity) (Rows, error) {
+	return GDAL.QueryRowsContext(ctx, entity)
+}
+
+// QueryRowsWithSQLContext 使用sql查询数据并逐行读取（支持上下文）
+func QueryRowsWithSQLContext(ctx context.Context, sql string, values ...interface{}) (Rows, error) {
+	return GDAL.QueryRowsWithSQLContext(ctx, sql, values...)
+}
+
+// QueryContext 查询数据（支持上下文）
+func QueryContext(ctx context.Context, entity QueryEntity) (interface{}, error) {
+	return GDAL.QueryContext(ctx, entity)
+}
+
+// ExecContext 执行单条事务性操作（支持上下文）
+func ExecContext(ctx context.Context, entity TranEntity) TranResult {
+	return GDAL.ExecContext(ctx, entity)
+}
+
+// ExecTransContext 执行多条事务性操作（支持上下文）
+func ExecTransContext(ctx context.Context, entities []TranEntity) TranResult {
+	return GDAL.ExecTransContext(ctx, entities)
+}
+
+// ExecWithSQLContext 执行sql语句（支持上下文）
+func ExecWithSQLContext(ctx context.Context, sql string, values ...interface{}) TranResult {
+	return GDAL.ExecWithSQLContext(ctx, sql, values...)
+}
+
+// Begin 开启事务
+func Begin() (Tx, error) {
+	return GDAL.Begin()
+}
+
+// BeginContext 开启事务（支持上下文）
+func BeginContext(ctx context.Context) (Tx, error) {
+	return GDAL.BeginContext(ctx)
+}
+
+// WithTx 在事务中执行fn，fn返回错误或发生panic时回滚，否则提交
+func WithTx(fn func(tx Provider) error) error {
+	return RunTx(context.Background(), GDAL, fn)
+}
+
+// WithTxContext 在事务中执行fn（支持上下文）
+func WithTxContext(ctx context.Context, fn func(tx Provider) error) error {
+	return RunTx(ctx, GDAL, fn)
+}
+
+// RunTx 使用指定的Provider开启事务并执行fn
+// 如果provider本身为事务，则以嵌套事务（保存点）执行，fn失败时只回滚嵌套部分
+func RunTx(ctx context.Context, provider Provider, fn func(tx Provider) error) (err error) {
+	tx, err := provider.BeginContext(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+	err = tx.Commit()
+	return
+}
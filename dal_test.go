@@ -0,0 +1,90 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeDBProvider 记录初始化配置，其他方法未实现
+type fakeDBProvider struct {
+	Provider
+	config string
+}
+
+func (p *fakeDBProvider) InitDB(config string) error {
+	if config == "" {
+		return errors.New("`config` can't be empty")
+	}
+	p.config = config
+	return nil
+}
+
+func init() {
+	RegisterDBProvider("fake", new(fakeDBProvider))
+}
+
+// unregister 测试结束后删除注册的实例，使测试可以重复执行
+func unregister(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		mux.Lock()
+		defer mux.Unlock()
+		for _, name := range names {
+			delete(instances, name)
+		}
+	})
+}
+
+func TestRegisterNamedProvider(t *testing.T) {
+	unregister(t, "fake_a", "fake_b", "fake_c", "fake_d")
+	if err := RegisterNamedProvider("fake_a", "fake", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterNamedProvider("fake_b", "fake", "b"); err != nil {
+		t.Fatal(err)
+	}
+	a, b := Use("fake_a").(*fakeDBProvider), Use("fake_b").(*fakeDBProvider)
+	if a == b || a.config != "a" || b.config != "b" {
+		t.Errorf("expected isolated instances, got %q %q", a.config, b.config)
+	}
+	if err := RegisterNamedProvider("fake_a", "fake", "c"); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+	if Use("fake_a").(*fakeDBProvider).config != "a" {
+		t.Error("duplicate registration replaced the instance")
+	}
+	if err := RegisterNamedProvider("fake_c", "unknown", "c"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+	if err := RegisterNamedProvider("fake_d", "fake", ""); err == nil {
+		t.Error("expected InitDB error")
+	}
+	if err := RegisterNamedProvider("fake_d", "fake", "d"); err != nil {
+		t.Errorf("failed registration should not reserve the name: %v", err)
+	}
+}
+
+func TestRegisterInstance(t *testing.T) {
+	unregister(t, "fake_instance", "fake_nil")
+	provide := &fakeDBProvider{config: "instance"}
+	if err := RegisterInstance("fake_instance", provide); err != nil {
+		t.Fatal(err)
+	}
+	if Use("fake_instance") != provide {
+		t.Error("unexpected instance")
+	}
+	if err := RegisterInstance("fake_instance", new(fakeDBProvider)); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+	if err := RegisterInstance("fake_nil", nil); err == nil {
+		t.Error("expected error for nil provider")
+	}
+}
+
+func TestUseUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unknown name")
+		}
+	}()
+	Use("fake_unknown")
+}
@@ -0,0 +1,51 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Call 拦截器获取的SQL语句执行信息
+type Call struct {
+	// Method 调用的Provider方法(例如：List、Pager、Exec)
+	// 事务语句(BEGIN、COMMIT、ROLLBACK及保存点)为Begin、Commit或Rollback，SQL为执行的语句
+	Method string
+	// Entity 调用的实体(QueryEntity或TranEntity)，使用SQL语句查询时为nil
+	Entity interface{}
+	// SQL 生成的SQL语句，可以在Before中修改
+	SQL string
+	// Values SQL语句的参数，可以在Before中修改
+	Values []interface{}
+	// Duration 执行耗时(查询操作不包括读取数据的时间)
+	Duration time.Duration
+	// RowsAffected 影响行数(仅执行操作)
+	RowsAffected int64
+	// Err 执行错误(包括Before返回的错误)
+	Err error
+}
+
+// Interceptor 拦截器，在Provider执行每条SQL语句前后调用
+type Interceptor interface {
+	// Before 执行前调用，返回错误时终止执行，该错误作为操作的结果返回
+	Before(ctx context.Context, call *Call) error
+	// After 执行后按照注册的相反顺序调用(仅调用Before执行成功的拦截器)
+	After(ctx context.Context, call *Call)
+}
+
+// Interceptable 支持注册拦截器的Provider
+type Interceptable interface {
+	// Intercept 注册拦截器(应在初始化时注册)
+	Intercept(interceptors ...Interceptor)
+}
+
+// Intercept 为Provider注册拦截器
+// 例如：dal.Intercept(dal.Use("audit"), new(AuditInterceptor))
+func Intercept(provide Provider, interceptors ...Interceptor) error {
+	p, ok := provide.(Interceptable)
+	if !ok {
+		return errors.New("The provider does not support interceptors")
+	}
+	p.Intercept(interceptors...)
+	return nil
+}
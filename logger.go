@@ -0,0 +1,81 @@
+package dal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+// 定义日志级别
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[LogLevel]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// LogEntry 结构化的日志字段
+type LogEntry struct {
+	// Level 日志级别
+	Level LogLevel
+	// Message 日志消息(例如：query、exec)
+	Message string
+	// Method 调用的Provider方法
+	Method string
+	// SQL 执行的SQL语句
+	SQL string
+	// Values SQL语句的参数
+	Values []interface{}
+	// Duration 执行耗时
+	Duration time.Duration
+	// Rows 查询返回的行数或执行影响的行数
+	Rows int64
+	// Err 执行错误
+	Err error
+}
+
+// Logger 日志接口，用于将Provider的日志输出到自定义的日志组件
+type Logger interface {
+	Log(ctx context.Context, entry LogEntry)
+}
+
+// LoggerFunc 使用函数实现Logger接口
+type LoggerFunc func(ctx context.Context, entry LogEntry)
+
+func (f LoggerFunc) Log(ctx context.Context, entry LogEntry) {
+	f(ctx, entry)
+}
+
+// Loggable 支持设置日志的Provider
+type Loggable interface {
+	// SetLogger 设置日志(应在初始化时设置)，logger为nil时不输出日志
+	SetLogger(logger Logger)
+}
+
+// SetLogger 设置Provider的日志
+// 例如：dal.SetLogger(dal.GDAL, dal.LoggerFunc(func(ctx context.Context, entry dal.LogEntry) { ... }))
+func SetLogger(provide Provider, logger Logger) error {
+	p, ok := provide.(Loggable)
+	if !ok {
+		return errors.New("The provider does not support logger")
+	}
+	p.SetLogger(logger)
+	return nil
+}
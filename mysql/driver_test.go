@@ -0,0 +1,224 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder 记录测试驱动执行的语句，查询返回预设的结果
+type recorder struct {
+	mu      sync.Mutex
+	log     []string
+	columns []fakeColumn
+	rows    [][]driver.Value
+	// failOn 执行包含该字符串的语句时返回failErr(未设置时为普通错误)
+	failOn  string
+	failErr error
+	// failTimes 返回错误的次数，0为不限制
+	failTimes int
+	failed    int
+	// result 返回执行语句的结果，未设置时影响行数为1
+	result func(query string) driver.Result
+	// closed 已关闭的结果集数量
+	closed int
+}
+
+type fakeColumn struct {
+	name     string
+	typeName string
+	scanType reflect.Type
+}
+
+func (r *recorder) record(query string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, query)
+	if r.failOn == "" || !strings.Contains(query, r.failOn) || r.failTimes > 0 && r.failed >= r.failTimes {
+		return nil
+	}
+	r.failed++
+	if r.failErr != nil {
+		return r.failErr
+	}
+	return errors.New("fake error: " + query)
+}
+
+func (r *recorder) execResult(query string) driver.Result {
+	if r.result != nil {
+		return r.result(query)
+	}
+	return driver.RowsAffected(1)
+}
+
+// fakeResult 包含新增ID及影响行数的执行结果
+type fakeResult struct {
+	id, rows int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.rows, nil }
+
+func (r *recorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+func (r *recorder) closedRows() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+func (r *recorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = nil
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[string]*recorder)
+)
+
+// openRecorder 打开使用记录驱动的连接池
+func openRecorder(t *testing.T) (*sql.DB, *recorder) {
+	r := new(recorder)
+	recordersMu.Lock()
+	recorders[t.Name()] = r
+	recordersMu.Unlock()
+	db, err := sql.Open("dal-record", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db, r
+}
+
+type recordDriver struct{}
+
+func (recordDriver) Open(name string) (driver.Conn, error) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[name]
+	if !ok {
+		return nil, errors.New("unknown recorder: " + name)
+	}
+	return &recordConn{r: r}, nil
+}
+
+type recordConn struct {
+	r *recorder
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{r: c.r, query: query}, nil
+}
+
+func (c *recordConn) Close() error { return nil }
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	if err := c.r.record("BEGIN"); err != nil {
+		return nil, err
+	}
+	return &recordTx{r: c.r}, nil
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.r.record(query); err != nil {
+		return nil, err
+	}
+	return c.r.execResult(query), nil
+}
+
+func (c *recordConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.r.record(query); err != nil {
+		return nil, err
+	}
+	return &recordRows{r: c.r, columns: c.r.columns, rows: c.r.rows}, nil
+}
+
+type recordTx struct {
+	r *recorder
+}
+
+func (tx *recordTx) Commit() error   { return tx.r.record("COMMIT") }
+func (tx *recordTx) Rollback() error { return tx.r.record("ROLLBACK") }
+
+type recordStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.r.record(s.query); err != nil {
+		return nil, err
+	}
+	return s.r.execResult(s.query), nil
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.r.record(s.query); err != nil {
+		return nil, err
+	}
+	return &recordRows{r: s.r, columns: s.r.columns, rows: s.r.rows}, nil
+}
+
+// recordRows 返回预设的数据，并提供列的类型名称及ScanType
+type recordRows struct {
+	r       *recorder
+	columns []fakeColumn
+	rows    [][]driver.Value
+	index   int
+}
+
+func (r *recordRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+func (r *recordRows) Close() error {
+	r.r.mu.Lock()
+	defer r.r.mu.Unlock()
+	r.r.closed++
+	return nil
+}
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.index])
+	r.index++
+	return nil
+}
+
+func (r *recordRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.columns[index].typeName
+}
+
+func (r *recordRows) ColumnTypeScanType(index int) reflect.Type {
+	if t := r.columns[index].scanType; t != nil {
+		return t
+	}
+	return reflect.TypeOf(sql.RawBytes{})
+}
+
+func init() {
+	sql.Register("dal-record", recordDriver{})
+}
@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// identifier 处理SQL语句中的标识符(表名、列名及别名)，校验后使用反引号转义
+type identifier struct{}
+
+// name 转义以.分隔的限定名称(例如：s.StuCode => `s`.`StuCode`)
+func (id identifier) name(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 3 {
+		return "", invalidIdent(name)
+	}
+	for i, part := range parts {
+		ident, ok := unquoteIdent(part)
+		if !ok {
+			return "", invalidIdent(name)
+		}
+		parts[i] = quoteIdent(ident)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// names 转义多个名称
+func (id identifier) names(names []string) ([]string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		v, err := id.name(name)
+		if err != nil {
+			return nil, err
+		}
+		quoted[i] = v
+	}
+	return quoted, nil
+}
+
+// alias 转义表或列的别名
+func (id identifier) alias(alias string) (string, error) {
+	ident, ok := unquoteIdent(alias)
+	if !ok {
+		return "", invalidIdent(alias)
+	}
+	return quoteIdent(ident), nil
+}
+
+// tableAlias 转义表名及别名
+func (id identifier) tableAlias(table, alias string) (string, error) {
+	table, err := id.name(table)
+	if err != nil || alias == "" {
+		return table, err
+	}
+	alias, err = id.alias(alias)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s AS %s", table, alias), nil
+}
+
+// fields 转义查询字段列表，支持*、t.*、col、t.col以及col AS alias
+func (id identifier) fields(fieldsSelect string) (string, error) {
+	items := strings.Split(fieldsSelect, ",")
+	for i, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "*" {
+			items[i] = item
+			continue
+		}
+		if strings.HasSuffix(item, ".*") {
+			table, err := id.name(strings.TrimSuffix(item, ".*"))
+			if err != nil {
+				return "", err
+			}
+			items[i] = table + ".*"
+			continue
+		}
+		tokens := strings.Fields(item)
+		switch {
+		case len(tokens) == 1:
+			field, err := id.name(tokens[0])
+			if err != nil {
+				return "", err
+			}
+			items[i] = field
+		case len(tokens) == 2 || len(tokens) == 3 && strings.EqualFold(tokens[1], "AS"):
+			field, err := id.name(tokens[0])
+			if err != nil {
+				return "", err
+			}
+			alias, err := id.alias(tokens[len(tokens)-1])
+			if err != nil {
+				return "", err
+			}
+			items[i] = fmt.Sprintf("%s AS %s", field, alias)
+		default:
+			return "", invalidIdent(item)
+		}
+	}
+	return strings.Join(items, ","), nil
+}
+
+// quoteIdent 使用反引号转义标识符，标识符中的反引号转义为两个反引号
+func quoteIdent(ident string) string {
+	return "`" + strings.Replace(ident, "`", "``", -1) + "`"
+}
+
+// unquoteIdent 校验标识符，已使用反引号转义的标识符返回转义前的名称
+func unquoteIdent(part string) (string, bool) {
+	if l := len(part); l > 2 && part[0] == '`' && part[l-1] == '`' {
+		inner := part[1 : l-1]
+		if strings.Contains(strings.Replace(inner, "``", "", -1), "`") {
+			return "", false
+		}
+		return strings.Replace(inner, "``", "`", -1), true
+	}
+	if part == "" {
+		return "", false
+	}
+	digits := true
+	for _, r := range part {
+		switch {
+		case unicode.IsDigit(r):
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+			digits = false
+		default:
+			return "", false
+		}
+	}
+	// 标识符不能全部为数字
+	return part, !digits
+}
+
+func invalidIdent(name string) error {
+	return fmt.Errorf("`%s` is not a valid identifier", name)
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestIdentifier(t *testing.T) {
+	var id identifier
+	valid := map[string]string{
+		"order":         "`order`",
+		"s.StuCode":     "`s`.`StuCode`",
+		"`order`":       "`order`",
+		"`a``b`":        "`a``b`",
+		"学生":            "`学生`",
+		"db.student.ID": "`db`.`student`.`ID`",
+	}
+	for name, expect := range valid {
+		quoted, err := id.name(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if quoted != expect {
+			t.Errorf("%s: unexpected %s", name, quoted)
+		}
+	}
+	for _, name := range []string{"", "1", "a b", "a;DROP TABLE t", "`a`b`", "a=1 OR 1", "COUNT(*)", "a.b.c.d"} {
+		if _, err := id.name(name); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestIdentifierFields(t *testing.T) {
+	var id identifier
+	fields, err := id.fields("*, s.*, StuCode, s.StuName AS Name, c.ClassName cname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "*,`s`.*,`StuCode`,`s`.`StuName` AS `Name`,`c`.`ClassName` AS `cname`"; fields != expect {
+		t.Errorf("unexpected fields: %s", fields)
+	}
+	if _, err := id.fields("StuCode, (SELECT 1) x"); err == nil {
+		t.Error("expected error for expression")
+	}
+}
+
+func TestGetTranSQLInvalidIdent(t *testing.T) {
+	mp := new(MysqlProvider)
+	entity := dal.NewTranAEntity("student", map[string]interface{}{"StuCode=1 OR 1": "x"}).Entity
+	if _, _, err := mp.getTranSQL(entity); err == nil {
+		t.Error("expected error for invalid column")
+	}
+	entity = dal.NewTranUEntity("student; DROP TABLE x", map[string]interface{}{"Age": 1}, dal.QueryCondition{}).Entity
+	if _, _, err := mp.getTranSQL(entity); err == nil {
+		t.Error("expected error for invalid table")
+	}
+}
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/antlinker/go-dal"
+)
+
+type callKey struct{}
+
+// callTag 上下文中记录的调用方法及实体
+type callTag struct {
+	method string
+	entity interface{}
+}
+
+// withCall 在上下文中记录调用的方法及实体
+// 已记录时保留外层调用的方法(例如ExecWithSQL调用Exec)，entity不为nil时替换实体
+func withCall(ctx context.Context, method string, entity interface{}) context.Context {
+	tag, ok := ctx.Value(callKey{}).(callTag)
+	if !ok {
+		return context.WithValue(ctx, callKey{}, callTag{method, entity})
+	}
+	if entity == nil {
+		return ctx
+	}
+	tag.entity = entity
+	return context.WithValue(ctx, callKey{}, tag)
+}
+
+// Intercept 注册拦截器，在执行每条SQL语句前后调用(应在初始化时注册)
+func (mp *MysqlProvider) Intercept(interceptors ...dal.Interceptor) {
+	mp.interceptors = append(mp.interceptors, interceptors...)
+}
+
+// intercept 依次调用拦截器的Before后执行fn，再按照相反顺序调用After，返回执行信息
+// Before返回错误时不执行fn，call.Err为该错误
+func (mp *MysqlProvider) intercept(ctx context.Context, query string, values []interface{}, fn func(call *dal.Call) error) *dal.Call {
+	call := &dal.Call{SQL: query, Values: values}
+	if tag, ok := ctx.Value(callKey{}).(callTag); ok {
+		call.Method, call.Entity = tag.method, tag.entity
+	}
+	var (
+		err     error
+		entered int
+	)
+	for _, interceptor := range mp.interceptors {
+		if err = interceptor.Before(ctx, call); err != nil {
+			break
+		}
+		entered++
+	}
+	if err == nil {
+		start := time.Now()
+		err = fn(call)
+		call.Duration = time.Since(start)
+	}
+	call.Err = err
+	for i := entered - 1; i >= 0; i-- {
+		mp.interceptors[i].After(ctx, call)
+	}
+	call.Err = err
+	return call
+}
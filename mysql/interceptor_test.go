@@ -0,0 +1,140 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+type recordInterceptor struct {
+	name  string
+	trace *[]string
+	veto  error
+	calls []dal.Call
+}
+
+func (r *recordInterceptor) Before(ctx context.Context, call *dal.Call) error {
+	*r.trace = append(*r.trace, r.name+".Before")
+	if r.veto != nil {
+		return r.veto
+	}
+	call.SQL += " /* traced */"
+	return nil
+}
+
+func (r *recordInterceptor) After(ctx context.Context, call *dal.Call) {
+	*r.trace = append(*r.trace, r.name+".After")
+	r.calls = append(r.calls, *call)
+}
+
+func TestIntercept(t *testing.T) {
+	db, err := sql.Open("dal-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var trace []string
+	first := &recordInterceptor{name: "first", trace: &trace}
+	second := &recordInterceptor{name: "second", trace: &trace}
+	mp := &MysqlProvider{db: db}
+	if err := dal.Intercept(mp, first, second); err != nil {
+		t.Fatal(err)
+	}
+	entity := dal.NewTranDEntity("student", dal.Where(dal.Eq("ID", 1)).Condition).Entity
+	result := mp.ExecWithSQL("DELETE FROM `student` WHERE `ID`=?", 1)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if result = mp.Exec(entity); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	expectTrace := []string{"first.Before", "second.Before", "second.After", "first.After"}
+	if !reflect.DeepEqual(trace, append(expectTrace, expectTrace...)) {
+		t.Errorf("unexpected trace: %v", trace)
+	}
+	call := second.calls[1]
+	if call.Method != "Exec" || !reflect.DeepEqual(call.Entity, entity) || call.RowsAffected != 1 {
+		t.Errorf("unexpected call: %+v", call)
+	}
+	if !strings.HasSuffix(call.SQL, "/* traced */ /* traced */") {
+		t.Errorf("unexpected sql: %s", call.SQL)
+	}
+	if method := second.calls[0].Method; method != "ExecWithSQL" {
+		t.Errorf("unexpected method: %s", method)
+	}
+
+	trace = nil
+	veto := errors.New("denied")
+	mp.interceptors = []dal.Interceptor{first, &recordInterceptor{name: "policy", trace: &trace, veto: veto}, second}
+	if result = mp.Exec(entity); result.Error != veto {
+		t.Errorf("expected veto error, got %v", result.Error)
+	}
+	if expect := []string{"first.Before", "policy.Before", "first.After"}; !reflect.DeepEqual(trace, expect) {
+		t.Errorf("unexpected trace: %v", trace)
+	}
+	if call := first.calls[len(first.calls)-1]; call.Err != veto {
+		t.Errorf("unexpected call error: %v", call.Err)
+	}
+}
+
+func TestInterceptTx(t *testing.T) {
+	db, _ := openRecorder(t)
+	var trace []string
+	record := &recordInterceptor{name: "record", trace: &trace}
+	mp := &MysqlProvider{db: db}
+	mp.Intercept(record)
+	tx, err := mp.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := tx.(*mysqlTx).Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nested.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	for _, call := range record.calls {
+		calls = append(calls, call.Method+":"+call.SQL)
+	}
+	expect := []string{
+		"Begin:BEGIN /* traced */",
+		"Begin:SAVEPOINT dal_sp_1 /* traced */",
+		"Rollback:ROLLBACK TO SAVEPOINT dal_sp_1 /* traced */",
+		"Commit:COMMIT /* traced */",
+	}
+	if !reflect.DeepEqual(calls, expect) {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+func TestInterceptTxVeto(t *testing.T) {
+	db, r := openRecorder(t)
+	var trace []string
+	mp := &MysqlProvider{db: db}
+	tx, err := mp.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	veto := errors.New("denied")
+	mp.interceptors = []dal.Interceptor{&recordInterceptor{name: "policy", trace: &trace, veto: veto}}
+	tx.(*mysqlTx).interceptors = mp.interceptors
+	if err := tx.Commit(); err != veto {
+		t.Errorf("expected veto error, got %v", err)
+	}
+	if expect := []string{"BEGIN", "ROLLBACK"}; !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+	if _, err := mp.Begin(); err != veto {
+		t.Errorf("expected veto error, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/antlinker/go-dal"
+)
+
+// stdLogger 默认日志，使用标准库log输出
+type stdLogger struct {
+	lg *log.Logger
+}
+
+func (l stdLogger) Log(ctx context.Context, entry dal.LogEntry) {
+	if entry.SQL == "" {
+		l.lg.Printf("[%s] %s:%v", entry.Level, entry.Message, entry.Err)
+		return
+	}
+	msg := "[%s] %s\nQuery SQL:\n%s \nQuery Params:%v\nRows:%d Duration:%v"
+	args := []interface{}{entry.Level, entry.Method, entry.SQL, entry.Values, entry.Rows, entry.Duration}
+	if entry.Err != nil {
+		msg += " Error:%v"
+		args = append(args, entry.Err)
+	}
+	l.lg.Printf(msg, args...)
+}
+
+// SetLogger 设置日志(应在初始化时设置)，logger为nil时不输出日志
+func (mp *MysqlProvider) SetLogger(logger dal.Logger) {
+	mp.logger = logger
+}
+
+func (mp *MysqlProvider) log(ctx context.Context, entry dal.LogEntry) {
+	if mp.logger != nil {
+		mp.logger.Log(ctx, entry)
+	}
+}
+
+// logLevel 获取语句的日志级别，不需要记录时返回false
+// IsPrint为true时记录所有语句(执行错误为Error级别)，执行时间超过SlowThreshold的语句为Warn级别
+func (mp *MysqlProvider) logLevel(call *dal.Call) (dal.LogLevel, bool) {
+	slow := mp.config.SlowThreshold > 0 && call.Duration >= mp.config.SlowThreshold
+	switch {
+	case call.Err != nil && mp.config.IsPrint:
+		return dal.LevelError, true
+	case slow:
+		return dal.LevelWarn, true
+	case mp.config.IsPrint:
+		return dal.LevelDebug, true
+	}
+	return 0, false
+}
+
+// logCall 记录执行的SQL语句，rows为查询返回的行数或执行影响的行数
+func (mp *MysqlProvider) logCall(ctx context.Context, message string, call *dal.Call, rows int64) {
+	level, ok := mp.logLevel(call)
+	if !ok {
+		return
+	}
+	mp.log(ctx, dal.LogEntry{
+		Level:    level,
+		Message:  message,
+		Method:   call.Method,
+		SQL:      call.SQL,
+		Values:   call.Values,
+		Duration: call.Duration,
+		Rows:     rows,
+		Err:      call.Err,
+	})
+}
+
+// queryRows 统计读取的行数，关闭时记录查询语句
+type queryRows struct {
+	*sql.Rows
+	mp     *MysqlProvider
+	ctx    context.Context
+	call   dal.Call
+	count  int64
+	closed bool
+}
+
+func (r *queryRows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+		return true
+	}
+	return false
+}
+
+func (r *queryRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		call := r.call
+		call.Err = r.Rows.Err()
+		r.mp.logCall(r.ctx, "query", &call, r.count)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestLogger(t *testing.T) {
+	db, err := sql.Open("dal-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var entries []dal.LogEntry
+	mp := &MysqlProvider{db: db}
+	err = dal.SetLogger(mp, dal.LoggerFunc(func(ctx context.Context, entry dal.LogEntry) {
+		entries = append(entries, entry)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	entity := dal.NewTranDEntity("student", dal.Where(dal.Eq("ID", 1)).Condition).Entity
+
+	if result := mp.ExecContext(ctx, entity); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if len(entries) != 0 {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+
+	mp.config.SlowThreshold = time.Nanosecond
+	mp.ExecContext(ctx, entity)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Level != dal.LevelWarn || entry.Method != "Exec" || entry.Rows != 1 ||
+		entry.SQL != "DELETE FROM `student` WHERE (`ID` = ?)" || len(entry.Values) != 1 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	entries = nil
+	mp.config.SlowThreshold = time.Hour
+	mp.config.IsPrint = true
+	mp.ExecContext(ctx, entity)
+	if _, err := mp.ListWithSQLContext(ctx, "SELECT * FROM `student`"); err == nil {
+		t.Fatal("expected query error")
+	}
+	if len(entries) != 2 || entries[0].Level != dal.LevelDebug || entries[1].Level != dal.LevelError ||
+		entries[1].Method != "ListWithSQL" || entries[1].Err == nil {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
@@ -2,31 +2,32 @@ package mysql
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/antlinker/go-dal"
 	"github.com/antlinker/go-dal/utils"
 
 	// 引入mysql驱动
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
 )
 
 // 定义默认值
 const (
-	DefaultMaxOpenConns    = 0
-	DefaultMaxIdleConns    = 500
-	DefaultConnMaxLifetime = time.Hour * 2
-)
-
-// 定义全局变量
-var (
-	GDB *sql.DB
+	DefaultMaxOpenConns     = 0
+	DefaultMaxIdleConns     = 500
+	DefaultConnMaxLifetime  = time.Hour * 2
+	DefaultRetryBackoff     = time.Millisecond * 20
+	DefaultRetryMaxBackoff  = time.Second
+	DefaultBatchSize        = 1000
+	DefaultMaxAllowedPacket = 4 << 20
 )
 
 // Config 配置参数
@@ -41,19 +42,85 @@ type Config struct {
 	ConnMaxLifetime time.Duration `json:"maxlifetime"`
 	// IsPrint 是否打印SQL
 	IsPrint bool `json:"print"`
+	// SlowThreshold 慢查询阈值，执行时间超过该值的语句以Warn级别记录(默认为0，不记录)
+	SlowThreshold time.Duration `json:"slowthreshold"`
+	// Retry 事务遇到死锁或锁等待超时时的重试策略
+	Retry RetryPolicy `json:"retry"`
+	// BatchSize 批量新增时每条INSERT语句的最大行数
+	BatchSize int `json:"batchsize"`
+	// StmtCache 预处理语句缓存的最大数量(默认为0，不启用缓存)
+	// 只缓存事务外的SELECT、INSERT、UPDATE、DELETE、REPLACE语句(不缓存批量新增的多行INSERT)
+	StmtCache int `json:"stmtcache"`
+	// Replicas 从库连接，查询操作使用从库，事务性操作及加锁读使用主库
+	Replicas []string `json:"replicas"`
+	// Balance 从库的负载均衡策略(roundrobin、random、leastconn，默认为roundrobin)
+	Balance string `json:"balance"`
+	// Tables 按时间分表的规则
+	Tables []TableRule `json:"tables"`
 }
 
-type mysqlProvider struct {
+// MysqlProvider mysql数据库的Provider实现，每个实例维护独立的连接池
+type MysqlProvider struct {
 	config Config
-	lg     *log.Logger
+	logger dal.Logger
+	db     *sql.DB
+	tx     *sql.Tx
+	txSeq  *int64
+	// maxPacket 数据库的max_allowed_packet
+	maxPacket int
+	// replicas 从库连接池
+	replicas []*sql.DB
+	// replicaSeq 从库轮询序号
+	replicaSeq *uint64
+	// stmts 每个连接池的预处理语句缓存
+	stmts map[*sql.DB]*stmtCache
+	// tableRules 按时间分表的规则(以逻辑表名为键)
+	tableRules map[string]TableRule
+	// tableCache 已存在的物理表缓存
+	tableCache *tableCache
+	// loc 数据源的时区(loc参数)，用于解析查询结果中的时间
+	loc *time.Location
+	// retry 事务重试策略(RetryPolicy)，SetRetryPolicy可以在运行时修改
+	retry *atomic.Value
+	// interceptors 执行SQL语句的拦截器
+	interceptors []dal.Interceptor
+}
+
+// NewProvider 创建新的MysqlProvider实例
+func NewProvider() dal.DBProvider {
+	return new(MysqlProvider)
+}
+
+// DB 获取当前实例的数据库连接池
+func (mp *MysqlProvider) DB() *sql.DB {
+	return mp.db
 }
 
-func (mp *mysqlProvider) PrintSQL(query string, values ...interface{}) {
-	msg := fmt.Sprintf("Query SQL:\n%s \nQuery Params:%v", query, values)
-	mp.lg.Println(msg)
+// location 获取数据源的时区，未设置时为UTC
+func (mp *MysqlProvider) location() *time.Location {
+	if mp.loc == nil {
+		return time.UTC
+	}
+	return mp.loc
+}
+
+// Close 关闭当前实例的数据库连接池(包括从库)
+func (mp *MysqlProvider) Close() error {
+	err := mp.db.Close()
+	for _, replica := range mp.replicas {
+		if e := replica.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
-func (mp *mysqlProvider) InitDB(config string) error {
+// PrintSQL 以Debug级别记录SQL语句
+func (mp *MysqlProvider) PrintSQL(query string, values ...interface{}) {
+	mp.log(context.Background(), dal.LogEntry{Level: dal.LevelDebug, Message: "sql", SQL: query, Values: values})
+}
+
+func (mp *MysqlProvider) InitDB(config string) error {
 	var cfg Config
 	if err := json.NewDecoder(bytes.NewBufferString(config)).Decode(&cfg); err != nil {
 		return err
@@ -61,41 +128,112 @@ func (mp *mysqlProvider) InitDB(config string) error {
 	if cfg.DataSource == "" {
 		return errors.New("`datasource` can't be empty")
 	}
-	db, err := sql.Open("mysql", cfg.DataSource)
-	if err != nil {
-		return err
-	}
-	err = db.Ping()
-	if err != nil {
-		return err
-	}
 	if v := cfg.MaxOpenConns; v < 0 {
 		cfg.MaxOpenConns = DefaultMaxOpenConns
 	}
-	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	if v := cfg.MaxIdleConns; v <= 0 {
 		cfg.MaxIdleConns = DefaultMaxIdleConns
 	}
-	db.SetMaxIdleConns(cfg.MaxIdleConns)
 	if v := cfg.ConnMaxLifetime; v <= 0 {
 		cfg.ConnMaxLifetime = DefaultConnMaxLifetime
 	}
-	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if cfg.Balance == "" {
+		cfg.Balance = BalanceRoundRobin
+	}
+	if _, ok := balancers[cfg.Balance]; !ok {
+		return fmt.Errorf("The unknown `balance`: %s", cfg.Balance)
+	}
+	// 分表规则及查询结果中的时间默认使用数据源的loc参数(未设置时为UTC)
+	loc := time.UTC
+	if dsn, err := mysqldriver.ParseDSN(cfg.DataSource); err == nil && dsn.Loc != nil {
+		loc = dsn.Loc
+	}
+	tableRules := make(map[string]TableRule)
+	for _, rule := range cfg.Tables {
+		rule, err := rule.normalize(loc)
+		if err != nil {
+			return err
+		}
+		if _, ok := tableRules[rule.Table]; ok {
+			return fmt.Errorf("Rule of `%s` has been registered", rule.Table)
+		}
+		tableRules[rule.Table] = rule
+	}
+	db, err := openDB(cfg.DataSource, cfg)
+	if err != nil {
+		return err
+	}
+	var replicas []*sql.DB
+	for _, dataSource := range cfg.Replicas {
+		replica, err := openDB(dataSource, cfg)
+		if err != nil {
+			db.Close()
+			for _, r := range replicas {
+				r.Close()
+			}
+			return err
+		}
+		replicas = append(replicas, replica)
+	}
+	cfg.Retry = cfg.Retry.normalize()
+	if v := cfg.BatchSize; v <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	mp.maxPacket = DefaultMaxAllowedPacket
+	var maxPacket int
+	if err := db.QueryRow("SELECT @@max_allowed_packet").Scan(&maxPacket); err == nil && maxPacket > 0 {
+		mp.maxPacket = maxPacket
+	}
+	if cfg.StmtCache > 0 {
+		mp.stmts = make(map[*sql.DB]*stmtCache)
+		for _, v := range append([]*sql.DB{db}, replicas...) {
+			mp.stmts[v] = newStmtCache(cfg.StmtCache)
+		}
+	}
 	mp.config = cfg
-	mp.lg = log.New(os.Stdout, "[go-dal-mysql]", log.Ltime)
-	GDB = db
+	mp.logger = stdLogger{log.New(os.Stdout, "[go-dal-mysql]", log.Ltime)}
+	mp.db = db
+	mp.replicas = replicas
+	mp.replicaSeq = new(uint64)
+	mp.tableRules = tableRules
+	mp.tableCache = newTableCache()
+	mp.loc = loc
+	mp.retry = new(atomic.Value)
+	mp.retry.Store(cfg.Retry)
 	return nil
 }
 
-func (mp *mysqlProvider) Single(entity dal.QueryEntity) (map[string]string, error) {
+// openDB 打开数据库连接池并设置连接参数
+func openDB(dataSource string, cfg Config) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dataSource)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, nil
+}
+
+func (mp *MysqlProvider) Single(entity dal.QueryEntity) (map[string]string, error) {
+	return mp.SingleContext(context.Background(), entity)
+}
+
+func (mp *MysqlProvider) SingleContext(ctx context.Context, entity dal.QueryEntity) (map[string]string, error) {
+	ctx = withCall(ctx, "Single", entity)
 	if entity.ResultType != dal.QSingle {
 		entity.ResultType = dal.QSingle
 	}
-	sqlText, values := mp.parseQuerySQL(entity)
-	if mp.config.IsPrint {
-		mp.PrintSQL(sqlText[0], values...)
+	stmts, err := mp.buildQuerySQL(ctx, entity)
+	if err != nil {
+		return nil, err
 	}
-	data, err := mp.queryData(sqlText[0], values...)
+	data, err := mp.queryData(ctx, stmts[0].text, stmts[0].values...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,54 +243,83 @@ func (mp *mysqlProvider) Single(entity dal.QueryEntity) (map[string]string, erro
 	return data[0], nil
 }
 
-func (mp *mysqlProvider) SingleWithSQL(sql string, values ...interface{}) (data map[string]string, err error) {
-	if mp.config.IsPrint {
-		mp.PrintSQL(sql, values...)
-	}
-	datas, err := mp.queryData(sql, values...)
+func (mp *MysqlProvider) SingleWithSQL(sql string, values ...interface{}) (map[string]string, error) {
+	return mp.SingleWithSQLContext(context.Background(), sql, values...)
+}
+
+func (mp *MysqlProvider) SingleWithSQLContext(ctx context.Context, sql string, values ...interface{}) (data map[string]string, err error) {
+	ctx = withCall(ctx, "SingleWithSQL", nil)
+	datas, err := mp.queryData(ctx, sql, values...)
 	if err != nil {
 		return nil, err
 	}
-	if len(data) > 0 {
+	if len(datas) > 0 {
 		data = datas[0]
 	}
 	return
 }
 
-func (mp *mysqlProvider) AssignSingle(entity dal.QueryEntity, output interface{}) error {
-	data, err := mp.Single(entity)
+func (mp *MysqlProvider) AssignSingle(entity dal.QueryEntity, output interface{}) error {
+	return mp.AssignSingleContext(context.Background(), entity, output)
+}
+
+func (mp *MysqlProvider) AssignSingleContext(ctx context.Context, entity dal.QueryEntity, output interface{}) error {
+	ctx = withCall(ctx, "AssignSingle", entity)
+	if entity.ResultType != dal.QSingle {
+		entity.ResultType = dal.QSingle
+	}
+	stmts, err := mp.buildQuerySQL(ctx, entity)
 	if err != nil {
 		return err
 	}
-	return utils.NewDecoder(&data).Decode(output)
+	return mp.assignSingle(ctx, stmts[0].text, stmts[0].values, output)
+}
+
+func (mp *MysqlProvider) AssignSingleWithSQL(sql string, values []interface{}, output interface{}) error {
+	return mp.AssignSingleWithSQLContext(context.Background(), sql, values, output)
 }
 
-func (mp *mysqlProvider) AssignSingleWithSQL(sql string, values []interface{}, output interface{}) (err error) {
-	data, err := mp.SingleWithSQL(sql, values...)
+func (mp *MysqlProvider) AssignSingleWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	ctx = withCall(ctx, "AssignSingleWithSQL", nil)
+	return mp.assignSingle(ctx, sql, values, output)
+}
+
+func (mp *MysqlProvider) assignSingle(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	datas, err := mp.queryTypedData(ctx, true, sql, values...)
 	if err != nil {
-		return
+		return err
 	}
-	err = utils.NewDecoder(&data).Decode(output)
-	return
+	data := make(map[string]interface{})
+	if len(datas) > 0 {
+		data = datas[0]
+	}
+	return utils.NewDecoder(&data).Decode(output)
 }
 
-func (mp *mysqlProvider) ListWithSQL(sql string, values ...interface{}) (data []map[string]string, err error) {
-	if mp.config.IsPrint {
-		mp.PrintSQL(sql, values...)
-	}
-	data, err = mp.queryData(sql, values...)
+func (mp *MysqlProvider) ListWithSQL(sql string, values ...interface{}) ([]map[string]string, error) {
+	return mp.ListWithSQLContext(context.Background(), sql, values...)
+}
+
+func (mp *MysqlProvider) ListWithSQLContext(ctx context.Context, sql string, values ...interface{}) (data []map[string]string, err error) {
+	ctx = withCall(ctx, "ListWithSQL", nil)
+	data, err = mp.queryData(ctx, sql, values...)
 	return
 }
 
-func (mp *mysqlProvider) List(entity dal.QueryEntity) ([]map[string]string, error) {
+func (mp *MysqlProvider) List(entity dal.QueryEntity) ([]map[string]string, error) {
+	return mp.ListContext(context.Background(), entity)
+}
+
+func (mp *MysqlProvider) ListContext(ctx context.Context, entity dal.QueryEntity) ([]map[string]string, error) {
+	ctx = withCall(ctx, "List", entity)
 	if entity.ResultType != dal.QList {
 		entity.ResultType = dal.QList
 	}
-	sqlText, values := mp.parseQuerySQL(entity)
-	if mp.config.IsPrint {
-		mp.PrintSQL(sqlText[0], values...)
+	stmts, err := mp.buildQuerySQL(ctx, entity)
+	if err != nil {
+		return nil, err
 	}
-	data, err := mp.queryData(sqlText[0], values...)
+	data, err := mp.queryData(ctx, stmts[0].text, stmts[0].values...)
 	if err != nil {
 		return nil, err
 	}
@@ -160,19 +327,31 @@ func (mp *mysqlProvider) List(entity dal.QueryEntity) ([]map[string]string, erro
 	return data, nil
 }
 
-func (mp *mysqlProvider) AssignList(entity dal.QueryEntity, output interface{}) error {
-	data, err := mp.List(entity)
+func (mp *MysqlProvider) AssignList(entity dal.QueryEntity, output interface{}) error {
+	return mp.AssignListContext(context.Background(), entity, output)
+}
+
+func (mp *MysqlProvider) AssignListContext(ctx context.Context, entity dal.QueryEntity, output interface{}) error {
+	ctx = withCall(ctx, "AssignList", entity)
+	if entity.ResultType != dal.QList {
+		entity.ResultType = dal.QList
+	}
+	stmts, err := mp.buildQuerySQL(ctx, entity)
 	if err != nil {
 		return err
 	}
-	return utils.NewDecoder(&data).Decode(output)
+	return mp.AssignListWithSQLContext(ctx, stmts[0].text, stmts[0].values, output)
 }
 
-func (mp *mysqlProvider) AssignListWithSQL(sql string, values []interface{}, output interface{}) (err error) {
-	if mp.config.IsPrint {
-		mp.PrintSQL(sql, values...)
-	}
-	data, err := mp.queryData(sql, values...)
+func (mp *MysqlProvider) AssignListWithSQL(sql string, values []interface{}, output interface{}) error {
+	return mp.AssignListWithSQLContext(context.Background(), sql, values, output)
+}
+
+func (mp *MysqlProvider) AssignListWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) (err error) {
+	ctx = withCall(ctx, "AssignListWithSQL", nil)
+	// 解析到相同类型时直接赋值，不保留原始文本
+	_, typed := output.(*[]map[string]interface{})
+	data, err := mp.queryTypedData(ctx, !typed, sql, values...)
 	if err != nil {
 		return
 	}
@@ -180,19 +359,24 @@ func (mp *mysqlProvider) AssignListWithSQL(sql string, values []interface{}, out
 	return
 }
 
-func (mp *mysqlProvider) Pager(entity dal.QueryEntity) (qResult dal.QueryPagerResult, err error) {
+func (mp *MysqlProvider) Pager(entity dal.QueryEntity) (dal.QueryPagerResult, error) {
+	return mp.PagerContext(context.Background(), entity)
+}
+
+func (mp *MysqlProvider) PagerContext(ctx context.Context, entity dal.QueryEntity) (qResult dal.QueryPagerResult, err error) {
+	ctx = withCall(ctx, "Pager", entity)
+	// 总数及数据查询使用同一从库，避免复制延迟导致结果不一致
+	ctx = mp.withReplica(ctx)
 	if entity.ResultType != dal.QPager {
 		entity.ResultType = dal.QPager
 	}
 
-	sqlText, values := mp.parseQuerySQL(entity)
-
-	var count int64
-	if mp.config.IsPrint {
-		mp.PrintSQL(sqlText[1], values...)
+	stmts, err := mp.buildQuerySQL(ctx, entity)
+	if err != nil {
+		return
 	}
-	row := GDB.QueryRow(sqlText[1], values...)
-	err = row.Scan(&count)
+
+	count, err := mp.queryCount(ctx, stmts[1].text, stmts[1].values...)
 	if err != nil {
 		return
 	} else if count == 0 {
@@ -200,127 +384,246 @@ func (mp *mysqlProvider) Pager(entity dal.QueryEntity) (qResult dal.QueryPagerRe
 	}
 	qResult.Total = count
 
-	if mp.config.IsPrint {
-		mp.PrintSQL(sqlText[0], values...)
+	rData, err := mp.queryTypedData(ctx, false, stmts[0].text, stmts[0].values...)
+	if err != nil {
+		return
 	}
+	qResult.Rows = rData
 
-	rData := make([]map[string]interface{}, 0)
-	data, err := mp.queryData(sqlText[0], values...)
+	return
+}
+
+func (mp *MysqlProvider) Cursor(entity dal.QueryEntity) (dal.QueryCursorResult, error) {
+	return mp.CursorContext(context.Background(), entity)
+}
+
+func (mp *MysqlProvider) CursorContext(ctx context.Context, entity dal.QueryEntity) (qResult dal.QueryCursorResult, err error) {
+	ctx = withCall(ctx, "Cursor", entity)
+	if entity.ResultType != dal.QCursor {
+		entity.ResultType = dal.QCursor
+	}
+	stmts, err := mp.buildQuerySQL(ctx, entity)
 	if err != nil {
 		return
 	}
-	if len(data) > 0 {
-		err = utils.NewDecoder(data).Decode(&rData)
-		if err != nil {
-			return
+	rows, err := mp.queryTypedData(ctx, false, stmts[0].text, stmts[0].values...)
+	if err != nil {
+		return
+	}
+	param := entity.CursorParam
+	hasMore := len(rows) > param.PageSize()
+	if hasMore {
+		rows = rows[:param.PageSize()]
+	}
+	var backward bool
+	if param.Cursor != "" {
+		_, backward, _ = dal.DecodeCursor(param.Cursor)
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
 		}
 	}
-	qResult.Rows = rData
+	return dal.NewCursorResult(rows, param, backward, hasMore)
+}
 
-	return
+func (mp *MysqlProvider) Query(entity dal.QueryEntity) (interface{}, error) {
+	return mp.QueryContext(context.Background(), entity)
 }
 
-func (mp *mysqlProvider) Query(entity dal.QueryEntity) (interface{}, error) {
+func (mp *MysqlProvider) QueryContext(ctx context.Context, entity dal.QueryEntity) (interface{}, error) {
 	switch entity.ResultType {
 	case dal.QSingle:
-		return mp.Single(entity)
+		return mp.SingleContext(ctx, entity)
 	case dal.QList:
-		return mp.List(entity)
+		return mp.ListContext(ctx, entity)
 	case dal.QPager:
-		return mp.Pager(entity)
+		return mp.PagerContext(ctx, entity)
+	case dal.QCursor:
+		return mp.CursorContext(ctx, entity)
 	}
 	return nil, errors.New("The unknown `ResultType`")
 }
 
-func (mp *mysqlProvider) Exec(entity dal.TranEntity) (result dal.TranResult) {
-	if entity.Table == "" {
+func (mp *MysqlProvider) Exec(entity dal.TranEntity) dal.TranResult {
+	return mp.ExecContext(context.Background(), entity)
+}
+
+// ExecContext 执行事务实体，新增(TA)时Result为新增ID，其它操作(包括TBA、TAU、TAI、TR)为影响行数
+// TAU的影响行数按照MySQL的规则计算：新增的行为1，更新的行为2，值未改变的行为0；REPLACE替换的行为2
+// 所有操作的影响行数及新增ID都保存在Results中
+func (mp *MysqlProvider) ExecContext(ctx context.Context, entity dal.TranEntity) (result dal.TranResult) {
+	ctx = withCall(ctx, "Exec", entity)
+	if entity.Table == "" && entity.Operate != dal.TSQL {
 		result.Error = errors.New("`Table` can't be empty")
 		return
 	}
-	var (
-		sqlText string
-		values  []interface{}
-		err     error
-	)
-	switch entity.Operate {
-	case dal.TA:
-		sqlText, values, err = mp.getInsertSQL(entity)
-	case dal.TU:
-		sqlText, values, err = mp.getUpdateSQL(entity)
-	case dal.TD:
-		sqlText, values, err = mp.getDeleteSQL(entity)
-	}
+	stmts, err := mp.getTranStmts(ctx, entity)
 	if err != nil {
 		result.Error = err
+		return
 	}
-	if mp.config.IsPrint {
-		mp.PrintSQL(sqlText, values...)
+	if len(stmts) > 1 {
+		return mp.ExecTransContext(ctx, []dal.TranEntity{entity})
 	}
-	sqlResult, err := GDB.Exec(sqlText, values...)
+	var execResult dal.ExecResult
+	err = mp.withRetry(ctx, func() (err error) {
+		execResult, err = mp.execEntity(ctx, entity, nil)
+		return
+	})
 	if err != nil {
 		result.Error = err
 		return
 	}
 	if entity.Operate == dal.TA {
-		result.Result, err = sqlResult.LastInsertId()
+		result.Result = execResult.LastInsertId
 	} else {
-		result.Result, err = sqlResult.RowsAffected()
-	}
-	if err != nil {
-		result.Error = err
+		result.Result = execResult.RowsAffected
 	}
+	result.Results = []dal.ExecResult{execResult}
 	return
 }
 
-func (mp *mysqlProvider) ExecTrans(entities []dal.TranEntity) (result dal.TranResult) {
+func (mp *MysqlProvider) ExecWithSQL(sql string, values ...interface{}) dal.TranResult {
+	return mp.ExecWithSQLContext(context.Background(), sql, values...)
+}
+
+// ExecWithSQLContext 执行sql语句，Result为影响行数
+func (mp *MysqlProvider) ExecWithSQLContext(ctx context.Context, sql string, values ...interface{}) dal.TranResult {
+	ctx = withCall(ctx, "ExecWithSQL", nil)
+	return mp.ExecContext(ctx, dal.NewTranSQLEntity(sql, values...).Entity)
+}
+
+func (mp *MysqlProvider) ExecTrans(entities []dal.TranEntity) dal.TranResult {
+	return mp.ExecTransContext(context.Background(), entities)
+}
+
+func (mp *MysqlProvider) ExecTransContext(ctx context.Context, entities []dal.TranEntity) (result dal.TranResult) {
+	ctx = withCall(ctx, "ExecTrans", nil)
 	if len(entities) == 0 {
 		result.Error = errors.New("`entities` can't be empty")
 		return
 	}
-	var (
-		sqlText    string
-		values     []interface{}
-		err        error
-		affectNums int64
-	)
-	tx, err := GDB.Begin()
-	if err != nil {
-		result.Error = err
-		return
-	}
-	for i, l := 0, len(entities); i < l; i++ {
-		entity := entities[i]
-		switch entity.Operate {
-		case dal.TA:
-			sqlText, values, err = mp.getInsertSQL(entity)
-		case dal.TU:
-			sqlText, values, err = mp.getUpdateSQL(entity)
-		case dal.TD:
-			sqlText, values, err = mp.getDeleteSQL(entity)
-		}
+	var results []dal.ExecResult
+	err := mp.withRetry(ctx, func() error {
+		tx, err := mp.beginTx(ctx)
 		if err != nil {
-			break
+			return err
 		}
-		if mp.config.IsPrint {
-			mp.PrintSQL(sqlText, values...)
-		}
-		sqlResult, err := tx.Exec(sqlText, values...)
+		results, err = tx.execEntities(ctx, entities)
 		if err != nil {
-			break
+			tx.Rollback()
+			return err
 		}
-		rowsAffected, _ := sqlResult.RowsAffected()
-		affectNums += rowsAffected
-	}
+		return tx.Commit()
+	})
 	if err != nil {
-		tx.Rollback()
 		result.Error = err
 		return
 	}
-	tx.Commit()
-	result.Result = affectNums
+	for _, r := range results {
+		result.Result += r.RowsAffected
+	}
+	result.Results = results
 	return
 }
 
+func (mp *MysqlProvider) Begin() (dal.Tx, error) {
+	return mp.BeginContext(context.Background())
+}
+
+// BeginContext 开启事务，如果当前已处于事务中，则创建保存点作为嵌套事务
+func (mp *MysqlProvider) BeginContext(ctx context.Context) (dal.Tx, error) {
+	ctx = withCall(ctx, "Begin", nil)
+	return mp.beginTx(ctx)
+}
+
+func (mp *MysqlProvider) beginTx(ctx context.Context) (*mysqlTx, error) {
+	provider := *mp
+	t := &mysqlTx{MysqlProvider: &provider}
+	if mp.tx == nil {
+		err := mp.txCall(ctx, "Begin", "BEGIN", func(call *dal.Call) (err error) {
+			provider.tx, err = mp.db.BeginTx(ctx, nil)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+		provider.txSeq = new(int64)
+		return t, nil
+	}
+	t.savepoint = fmt.Sprintf("dal_sp_%d", atomic.AddInt64(mp.txSeq, 1))
+	if err := mp.txCall(ctx, "Begin", "SAVEPOINT "+t.savepoint, mp.txExec(ctx)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// txCall 执行事务语句(BEGIN、COMMIT、ROLLBACK及保存点)，与其他语句一样经过拦截器并记录日志
+// method为Begin、Commit或Rollback，替换上下文中记录的外层调用方法
+func (mp *MysqlProvider) txCall(ctx context.Context, method, query string, fn func(call *dal.Call) error) error {
+	ctx = context.WithValue(ctx, callKey{}, callTag{method: method})
+	call := mp.intercept(ctx, query, nil, fn)
+	mp.logCall(ctx, "exec", call, 0)
+	return call.Err
+}
+
+// txExec 在事务中执行保存点语句
+func (mp *MysqlProvider) txExec(ctx context.Context) func(call *dal.Call) error {
+	return func(call *dal.Call) error {
+		_, err := mp.tx.ExecContext(ctx, call.SQL, call.Values...)
+		return err
+	}
+}
+
+type mysqlTx struct {
+	*MysqlProvider
+	savepoint string
+	done      bool
+}
+
+func (t *mysqlTx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	ctx := context.Background()
+	if t.savepoint != "" {
+		return t.txCall(ctx, "Commit", "RELEASE SAVEPOINT "+t.savepoint, t.txExec(ctx))
+	}
+	var committed bool
+	err := t.txCall(ctx, "Commit", "COMMIT", func(call *dal.Call) error {
+		committed = true
+		return t.tx.Commit()
+	})
+	if !committed {
+		// 拦截器终止提交时回滚事务，避免连接未释放
+		t.tx.Rollback()
+	}
+	return err
+}
+
+func (t *mysqlTx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	ctx := context.Background()
+	if t.savepoint != "" {
+		return t.txCall(ctx, "Rollback", "ROLLBACK TO SAVEPOINT "+t.savepoint, t.txExec(ctx))
+	}
+	var rolledBack bool
+	err := t.txCall(ctx, "Rollback", "ROLLBACK", func(call *dal.Call) error {
+		rolledBack = true
+		return t.tx.Rollback()
+	})
+	if !rolledBack {
+		// 拦截器不能终止回滚
+		t.tx.Rollback()
+	}
+	return err
+}
+
 func init() {
-	dal.RegisterDBProvider(dal.MYSQL, new(mysqlProvider))
+	dal.RegisterDBProviderFactory(dal.MYSQL, NewProvider)
 }
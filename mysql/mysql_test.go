@@ -1,6 +1,12 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -89,3 +95,147 @@ func TestDelete(t *testing.T) {
 	}
 	t.Log("Delete:", result.Result)
 }
+
+func TestExecResult(t *testing.T) {
+	db, r := openRecorder(t)
+	results := map[string]fakeResult{
+		"INSERT INTO":      {id: 10, rows: 1},
+		"INSERT IGNORE":    {id: 0, rows: 0},
+		"REPLACE INTO":     {id: 11, rows: 2},
+		"ON DUPLICATE KEY": {id: 12, rows: 2},
+		"),(":              {id: 20, rows: 2},
+	}
+	r.result = func(query string) driver.Result {
+		for _, key := range []string{"ON DUPLICATE KEY", "),(", "INSERT IGNORE", "REPLACE INTO", "INSERT INTO"} {
+			if strings.Contains(query, key) {
+				return results[key]
+			}
+		}
+		return driver.RowsAffected(1)
+	}
+	mp := &MysqlProvider{db: db}
+	data := map[string]interface{}{"StuCode": "S001", "StuName": "Lyric"}
+	batch := []map[string]interface{}{data, {"StuCode": "S002", "StuName": "Lyric02"}}
+	for _, item := range []struct {
+		entity dal.TranEntity
+		result int64
+		exec   dal.ExecResult
+	}{
+		{dal.NewTranAEntity("student", data).Entity, 10, dal.ExecResult{RowsAffected: 1, LastInsertId: 10}},
+		{dal.NewTranIgnoreEntity("student", data).Entity, 0, dal.ExecResult{}},
+		{dal.NewTranReplaceEntity("student", data).Entity, 2, dal.ExecResult{RowsAffected: 2, LastInsertId: 11}},
+		// 冲突并更新时MySQL的影响行数为2
+		{dal.NewTranUpsertEntity("student", data, []string{"StuName"}).Entity, 2, dal.ExecResult{RowsAffected: 2, LastInsertId: 12}},
+		{dal.NewTranBatchAEntity("student", batch).Entity, 2, dal.ExecResult{RowsAffected: 2, LastInsertId: 20}},
+	} {
+		result := mp.Exec(item.entity)
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		if result.Result != item.result || !reflect.DeepEqual(result.Results, []dal.ExecResult{item.exec}) {
+			t.Errorf("unexpected result of %v: %d %v", item.entity.Operate, result.Result, result.Results)
+		}
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	db, r := openRecorder(t)
+	mp := &MysqlProvider{db: db}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mp.QueryContext(ctx, dal.NewQueryEntity("student", dal.QueryCondition{}, "*")(dal.QList).Entity); err != context.Canceled {
+		t.Errorf("unexpected query error: %v", err)
+	}
+	if stmts := r.statements(); len(stmts) != 0 {
+		t.Errorf("unexpected statements: %v", stmts)
+	}
+
+	// 执行第一个实体时取消，事务回滚且不再执行后面的实体
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	r.result = func(query string) driver.Result {
+		cancel()
+		return driver.RowsAffected(1)
+	}
+	result := mp.ExecTransContext(ctx, []dal.TranEntity{
+		dal.NewTranAEntity("student", map[string]interface{}{"StuCode": "S001"}).Entity,
+		dal.NewTranAEntity("student", map[string]interface{}{"StuCode": "S002"}).Entity,
+	})
+	if result.Error == nil {
+		t.Fatal("expected error for canceled context")
+	}
+	// 上下文取消后database/sql在后台回滚事务
+	deadline := time.Now().Add(time.Second)
+	for countStatements(r.statements(), "ROLLBACK") == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	expect := []string{"BEGIN", "INSERT INTO `student`(`StuCode`) VALUES(?)", "ROLLBACK"}
+	if stmts := r.statements(); !reflect.DeepEqual(stmts, expect) {
+		t.Errorf("unexpected statements: %v", stmts)
+	}
+}
+
+// runTxPanic 在RunTx中执行语句后panic，返回recover的值
+func runTxPanic(provider dal.Provider) (recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	dal.RunTx(context.Background(), provider, func(tx dal.Provider) error {
+		tx.ExecWithSQL("UPDATE a SET x=?", 1)
+		panic("boom")
+	})
+	return nil
+}
+
+func TestRunTxPanic(t *testing.T) {
+	db, r := openRecorder(t)
+	mp := &MysqlProvider{db: db}
+	if v := runTxPanic(mp); v != "boom" {
+		t.Errorf("expected panic, got %v", v)
+	}
+	if expect := []string{"BEGIN", "UPDATE a SET x=?", "ROLLBACK"}; !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	db, r := openRecorder(t)
+	gdal := dal.GDAL
+	defer func() {
+		dal.GDAL = gdal
+	}()
+	dal.GDAL = &MysqlProvider{db: db}
+
+	err := dal.WithTx(func(tx dal.Provider) error {
+		return tx.ExecWithSQL("UPDATE a SET x=?", 1).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	err = dal.WithTx(func(tx dal.Provider) error {
+		tx.ExecWithSQL("UPDATE b SET x=?", 2)
+		return failed
+	})
+	if err != failed {
+		t.Errorf("expected fn error, got %v", err)
+	}
+	tx, err := dal.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != sql.ErrTxDone {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+	expect := []string{
+		"BEGIN", "UPDATE a SET x=?", "COMMIT",
+		"BEGIN", "UPDATE b SET x=?", "ROLLBACK",
+		"BEGIN", "ROLLBACK",
+	}
+	if !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+}
@@ -1,63 +1,449 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/antlinker/go-dal"
 )
 
-func (mp *mysqlProvider) getInsertSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
+// executor 提供*sql.DB与*sql.Tx共同的执行方法
+type executor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (mp *MysqlProvider) executor() executor {
+	if mp.tx != nil {
+		return mp.tx
+	}
+	return mp.db
+}
+
+// reader 获取查询使用的执行器及连接池
+// 事务中、未配置从库、使用dal.WithPrimary指定主库或加锁读(FOR UPDATE等)时使用主库，
+// 上下文中已记录从库(withReplica)时使用该从库，否则按照负载均衡策略选择从库
+func (mp *MysqlProvider) reader(ctx context.Context, query string) (executor, *sql.DB) {
+	if mp.tx != nil {
+		return mp.tx, mp.db
+	}
+	if len(mp.replicas) == 0 || dal.IsPrimary(ctx) || isLockingRead(query) {
+		return mp.db, mp.db
+	}
+	if db := mp.pinnedReplica(ctx); db != nil {
+		return db, db
+	}
+	db := balancers[mp.config.Balance](mp.replicas, mp.replicaSeq)
+	return db, db
+}
+
+func (mp *MysqlProvider) query(ctx context.Context, query string, values ...interface{}) (*queryRows, error) {
+	var rows *sql.Rows
+	call := mp.intercept(ctx, query, values, func(call *dal.Call) (err error) {
+		exec, db := mp.reader(ctx, call.SQL)
+		if stmt, release := mp.prepared(ctx, db, call.SQL); stmt != nil {
+			defer release()
+			rows, err = stmt.QueryContext(ctx, call.Values...)
+			return
+		}
+		rows, err = exec.QueryContext(ctx, call.SQL, call.Values...)
+		return
+	})
+	if call.Err != nil {
+		mp.logCall(ctx, "query", call, 0)
+		return nil, call.Err
+	}
+	return &queryRows{Rows: rows, mp: mp, ctx: ctx, call: *call}, nil
+}
+
+// queryCount 查询总数
+func (mp *MysqlProvider) queryCount(ctx context.Context, query string, values ...interface{}) (count int64, err error) {
+	rows, err := mp.query(ctx, query, values...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+func (mp *MysqlProvider) exec(ctx context.Context, query string, values ...interface{}) (result sql.Result, err error) {
+	call := mp.intercept(ctx, query, values, func(call *dal.Call) (err error) {
+		if stmt, release := mp.prepared(ctx, mp.db, call.SQL); stmt != nil {
+			defer release()
+			result, err = stmt.ExecContext(ctx, call.Values...)
+		} else {
+			result, err = mp.executor().ExecContext(ctx, call.SQL, call.Values...)
+		}
+		if err == nil {
+			call.RowsAffected, _ = result.RowsAffected()
+		}
+		return
+	})
+	mp.logCall(ctx, "exec", call, call.RowsAffected)
+	return result, call.Err
+}
+
+// execEntities 依次执行事务实体，返回每个实体的执行结果
+// 执行失败时返回*dal.TranError
+func (mp *MysqlProvider) execEntities(ctx context.Context, entities []dal.TranEntity) (results []dal.ExecResult, err error) {
+	results = make([]dal.ExecResult, len(entities))
+	for i, l := 0, len(entities); i < l; i++ {
+		results[i], err = mp.execEntity(withCall(ctx, "", entities[i]), entities[i], results[:i])
+		if err != nil {
+			return nil, &dal.TranError{Index: i, Err: err}
+		}
+	}
+	return
+}
+
+// execEntity 执行单个事务实体，prev为前面实体的执行结果，用于解析新增ID引用
+func (mp *MysqlProvider) execEntity(ctx context.Context, entity dal.TranEntity, prev []dal.ExecResult) (result dal.ExecResult, err error) {
+	stmts, err := mp.getTranStmts(ctx, entity)
+	if err != nil {
+		return
+	}
+	for i, stmt := range stmts {
+		values, err := resolveInsertID(stmt.values, prev)
+		if err != nil {
+			return result, err
+		}
+		sqlResult, err := mp.exec(ctx, stmt.text, values...)
+		if err != nil {
+			return result, err
+		}
+		rowsAffected, _ := sqlResult.RowsAffected()
+		result.RowsAffected += rowsAffected
+		if i == 0 {
+			result.LastInsertId, _ = sqlResult.LastInsertId()
+		}
+	}
+	return
+}
+
+// resolveInsertID 将参数中的新增ID引用替换为前面实体的新增ID
+func resolveInsertID(values []interface{}, prev []dal.ExecResult) ([]interface{}, error) {
+	var resolved []interface{}
+	for i, v := range values {
+		ref, ok := v.(dal.InsertIDRef)
+		if !ok {
+			continue
+		}
+		index := ref.Index()
+		if index < 0 || index >= len(prev) {
+			return nil, fmt.Errorf("invalid insert id reference: %d", index)
+		}
+		if resolved == nil {
+			resolved = make([]interface{}, len(values))
+			copy(resolved, values)
+		}
+		resolved[i] = prev[index].LastInsertId
+	}
+	if resolved == nil {
+		return values, nil
+	}
+	return resolved, nil
+}
+
+// getTranStmts 获取事务实体对应的SQL语句（批量新增或按时间分表时可能有多条）
+func (mp *MysqlProvider) getTranStmts(ctx context.Context, entity dal.TranEntity) (stmts []sqlStmt, err error) {
+	entities, err := mp.tranTables(ctx, entity)
+	if err != nil {
+		return
+	}
+	for _, item := range entities {
+		if item.Operate == dal.TBA {
+			var batch []sqlStmt
+			batch, err = mp.getBatchInsertSQL(item)
+			if err != nil {
+				return
+			}
+			stmts = append(stmts, batch...)
+			continue
+		}
+		sqlText, values, err := mp.getTranSQL(item)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, sqlStmt{sqlText, values})
+	}
+	return
+}
+
+func (mp *MysqlProvider) getTranSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
+	switch entity.Operate {
+	case dal.TA, dal.TAU, dal.TAI, dal.TR:
+		sqlText, values, err = mp.getInsertSQL(entity)
+	case dal.TU:
+		sqlText, values, err = mp.getUpdateSQL(entity)
+	case dal.TD:
+		sqlText, values, err = mp.getDeleteSQL(entity)
+	case dal.TSQL:
+		if entity.SQL == "" {
+			err = errors.New("`SQL` can't be empty")
+			return
+		}
+		sqlText, values = entity.SQL, entity.Values
+	default:
+		err = errors.New("The unknown `Operate`")
+	}
+	return
+}
+
+func (mp *MysqlProvider) getInsertSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
 	if len(entity.FieldsValue) == 0 {
 		err = errors.New("`FieldsValue` can't be empty")
 		return
 	}
+	id := identifier{}
+	table, err := id.name(entity.Table)
+	if err != nil {
+		return
+	}
 	var (
+		field        string
 		fields       []string
 		placeholders []string
 	)
-	for k, v := range entity.FieldsValue {
-		fields = append(fields, k)
+	for _, k := range sortedKeys(entity.FieldsValue) {
+		field, err = id.name(k)
+		if err != nil {
+			return
+		}
+		fields = append(fields, field)
 		placeholders = append(placeholders, "?")
-		values = append(values, v)
+		values = append(values, entity.FieldsValue[k])
+	}
+	verb := "INSERT INTO"
+	switch entity.Operate {
+	case dal.TAI:
+		verb = "INSERT IGNORE INTO"
+	case dal.TR:
+		verb = "REPLACE INTO"
 	}
-	sqlText = fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", entity.Table, strings.Join(fields, ","), strings.Join(placeholders, ","))
+	sqlText = fmt.Sprintf("%s %s(%s) VALUES(%s)", verb, table, strings.Join(fields, ","), strings.Join(placeholders, ","))
+	if entity.Operate == dal.TAU {
+		updateSQL, updateValues, err := getUpsertClause(id, entity, fields)
+		if err != nil {
+			return "", nil, err
+		}
+		sqlText = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", sqlText, updateSQL)
+		values = append(values, updateValues...)
+	}
+	return
+}
+
+// getUpsertClause 获取ON DUPLICATE KEY UPDATE的更新部分，fields为已转义的新增列
+func getUpsertClause(id identifier, entity dal.TranEntity, fields []string) (string, []interface{}, error) {
+	var (
+		sets   []string
+		values []interface{}
+	)
+	if len(entity.UpdateValues) > 0 {
+		for _, k := range sortedKeys(entity.UpdateValues) {
+			field, err := id.name(k)
+			if err != nil {
+				return "", nil, err
+			}
+			if expr, ok := entity.UpdateValues[k].(dal.SQLExpr); ok {
+				sets = append(sets, fmt.Sprintf("%s=%s", field, expr.SQL))
+				values = append(values, expr.Values...)
+				continue
+			}
+			sets = append(sets, fmt.Sprintf("%s=?", field))
+			values = append(values, entity.UpdateValues[k])
+		}
+		return strings.Join(sets, ","), values, nil
+	}
+	updateFields := fields
+	if len(entity.UpdateFields) > 0 {
+		var err error
+		updateFields, err = id.names(entity.UpdateFields)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	for _, field := range updateFields {
+		sets = append(sets, fmt.Sprintf("%s=VALUES(%s)", field, field))
+	}
+	return strings.Join(sets, ","), values, nil
+}
+
+// sortedKeys 获取排序后的键，保证相同的数据生成相同的SQL语句
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// maxPlaceholders 单条语句允许的最大参数数量
+const maxPlaceholders = 65535
+
+// getBatchInsertSQL 生成多行INSERT语句，按照行数、参数数量及max_allowed_packet拆分
+func (mp *MysqlProvider) getBatchInsertSQL(entity dal.TranEntity) (stmts []sqlStmt, err error) {
+	if len(entity.BatchValues) == 0 {
+		err = errors.New("`BatchValues` can't be empty")
+		return
+	}
+	columnSet := make(map[string]bool)
+	for _, row := range entity.BatchValues {
+		for k := range row {
+			columnSet[k] = true
+		}
+	}
+	if len(columnSet) == 0 {
+		err = errors.New("`BatchValues` can't be empty")
+		return
+	}
+	columns := make([]string, 0, len(columnSet))
+	for k := range columnSet {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	id := identifier{}
+	table, err := id.name(entity.Table)
+	if err != nil {
+		return
+	}
+	quoted, err := id.names(columns)
+	if err != nil {
+		return
+	}
+
+	batchSize := mp.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	maxPacket := mp.maxPacket
+	if maxPacket <= 0 {
+		maxPacket = DefaultMaxAllowedPacket
+	}
+	// 预留部分空间用于协议头等额外开销
+	maxPacket = maxPacket / 10 * 9
+
+	header := fmt.Sprintf("INSERT INTO %s(%s) VALUES", table, strings.Join(quoted, ","))
+	var (
+		rowsSQL []string
+		values  []interface{}
+		size    int
+	)
+	flush := func() {
+		if len(rowsSQL) == 0 {
+			return
+		}
+		stmts = append(stmts, sqlStmt{header + strings.Join(rowsSQL, ","), values})
+		rowsSQL, values, size = nil, nil, 0
+	}
+	for _, row := range entity.BatchValues {
+		var (
+			placeholders []string
+			rowValues    []interface{}
+			rowSize      int
+		)
+		for _, column := range columns {
+			v, ok := row[column]
+			if !ok {
+				placeholders = append(placeholders, "DEFAULT")
+				rowSize += len("DEFAULT")
+				continue
+			}
+			placeholders = append(placeholders, "?")
+			rowValues = append(rowValues, v)
+			rowSize += estimateSize(v)
+		}
+		rowSQL := fmt.Sprintf("(%s)", strings.Join(placeholders, ","))
+		rowSize += len(rowSQL)
+		if len(rowsSQL) >= batchSize ||
+			len(values)+len(rowValues) > maxPlaceholders ||
+			len(header)+size+rowSize > maxPacket {
+			flush()
+		}
+		rowsSQL = append(rowsSQL, rowSQL)
+		values = append(values, rowValues...)
+		size += rowSize
+	}
+	flush()
 	return
 }
 
-func (mp *mysqlProvider) getUpdateSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
+// estimateSize 估算参数在数据包中占用的字节数
+func estimateSize(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v) + 9
+	case []byte:
+		return len(v) + 9
+	default:
+		return 16
+	}
+}
+
+func (mp *MysqlProvider) getUpdateSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
 	if len(entity.FieldsValue) == 0 {
 		err = errors.New("`FieldsValue` can't be empty")
 		return
 	}
+	id := identifier{}
+	table, err := id.name(entity.Table)
+	if err != nil {
+		return
+	}
 	var (
+		field  string
 		fields []string
 	)
-	for k, v := range entity.FieldsValue {
-		fields = append(fields, fmt.Sprintf("%s=?", k))
-		values = append(values, v)
+	for _, k := range sortedKeys(entity.FieldsValue) {
+		field, err = id.name(k)
+		if err != nil {
+			return
+		}
+		fields = append(fields, fmt.Sprintf("%s=?", field))
+		values = append(values, entity.FieldsValue[k])
 	}
-	condSQL, condValues, err := mp.parseCondition(entity.Condition)
+	condSQL, condValues, err := mp.parseCondition(id, entity.Condition)
 	if err != nil {
 		return
 	}
 	values = append(values, condValues...)
-	sqlText = fmt.Sprintf("UPDATE %s SET %s %s", entity.Table, strings.Join(fields, ","), condSQL)
+	sqlText = fmt.Sprintf("UPDATE %s SET %s %s", table, strings.Join(fields, ","), condSQL)
 	return
 }
 
-func (mp *mysqlProvider) getDeleteSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
-	sqlText, values, err = mp.parseCondition(entity.Condition)
+func (mp *MysqlProvider) getDeleteSQL(entity dal.TranEntity) (sqlText string, values []interface{}, err error) {
+	id := identifier{}
+	table, err := id.name(entity.Table)
 	if err != nil {
 		return
 	}
-	sqlText = fmt.Sprintf("DELETE FROM %s %s", entity.Table, sqlText)
+	sqlText, values, err = mp.parseCondition(id, entity.Condition)
+	if err != nil {
+		return
+	}
+	sqlText = fmt.Sprintf("DELETE FROM %s %s", table, sqlText)
 	return
 }
 
-func (mp *mysqlProvider) parseCondition(cond dal.QueryCondition) (sqlText string, values []interface{}, err error) {
+func (mp *MysqlProvider) parseCondition(id identifier, cond dal.QueryCondition) (sqlText string, values []interface{}, err error) {
+	return mp.parseClause(id, cond, "WHERE")
+}
+
+// parseClause 解析条件并以keyword(WHERE/HAVING)作为前缀，COND_CV类型的条件原样输出
+func (mp *MysqlProvider) parseClause(id identifier, cond dal.QueryCondition, keyword string) (sqlText string, values []interface{}, err error) {
 	switch cond.CType {
 	case dal.COND_KV:
 		if len(cond.FieldsKv) == 0 {
@@ -65,13 +451,23 @@ func (mp *mysqlProvider) parseCondition(cond dal.QueryCondition) (sqlText string
 			return
 		}
 		var (
+			field  string
 			fields []string
 		)
-		for k, v := range cond.FieldsKv {
-			fields = append(fields, fmt.Sprintf("%s=?", k))
+		for _, k := range sortedKeys(cond.FieldsKv) {
+			field, err = id.name(k)
+			if err != nil {
+				return
+			}
+			v := cond.FieldsKv[k]
+			if v == nil {
+				fields = append(fields, fmt.Sprintf("%s IS NULL", field))
+				continue
+			}
+			fields = append(fields, fmt.Sprintf("%s=?", field))
 			values = append(values, v)
 		}
-		sqlText = fmt.Sprintf("WHERE %s", strings.Join(fields, " and "))
+		sqlText = fmt.Sprintf("%s %s", keyword, strings.Join(fields, " and "))
 	case dal.COND_CV:
 		if cond.Condition == "" {
 			err = errors.New("`Condition` can't be empty")
@@ -79,61 +475,435 @@ func (mp *mysqlProvider) parseCondition(cond dal.QueryCondition) (sqlText string
 		}
 		sqlText = cond.Condition
 		values = cond.Values
+	case dal.COND_EXPR:
+		sqlText, values, err = mp.parseCondExpr(id, cond.Expr)
+		if err != nil {
+			return
+		}
+		sqlText = fmt.Sprintf("%s %s", keyword, sqlText)
 	default:
 		err = errors.New("`QueryCondition` can't be empty")
 	}
 	return
 }
 
-func (mp *mysqlProvider) parseQuerySQL(entity dal.QueryEntity) (sqlText []string, values []interface{}) {
-	if entity.FieldsSelect == "" {
+// parseQueryClause 解析查询条件，查询时允许条件为空
+func (mp *MysqlProvider) parseQueryClause(id identifier, cond dal.QueryCondition, keyword string) (string, []interface{}, error) {
+	switch {
+	case cond.CType == 0,
+		cond.CType == dal.COND_KV && len(cond.FieldsKv) == 0,
+		cond.CType == dal.COND_CV && cond.Condition == "":
+		return "", nil, nil
+	}
+	return mp.parseClause(id, cond, keyword)
+}
+
+var compareOps = map[dal.CondOp]string{
+	dal.OpEq:      "=",
+	dal.OpNe:      "<>",
+	dal.OpGt:      ">",
+	dal.OpGe:      ">=",
+	dal.OpLt:      "<",
+	dal.OpLe:      "<=",
+	dal.OpLike:    "LIKE",
+	dal.OpNotLike: "NOT LIKE",
+}
+
+func (mp *MysqlProvider) parseCondExpr(id identifier, expr dal.CondExpr) (sqlText string, values []interface{}, err error) {
+	switch expr.Op {
+	case dal.OpAnd, dal.OpOr:
+		if len(expr.Exprs) == 0 {
+			if expr.Op == dal.OpAnd {
+				sqlText = "1=1"
+			} else {
+				sqlText = "1=0"
+			}
+			return
+		}
+		sep := " AND "
+		if expr.Op == dal.OpOr {
+			sep = " OR "
+		}
+		items := make([]string, len(expr.Exprs))
+		for i, item := range expr.Exprs {
+			itemSQL, itemValues, itemErr := mp.parseCondExpr(id, item)
+			if itemErr != nil {
+				err = itemErr
+				return
+			}
+			items[i] = itemSQL
+			values = append(values, itemValues...)
+		}
+		sqlText = fmt.Sprintf("(%s)", strings.Join(items, sep))
+		return
+	case dal.OpNot:
+		if len(expr.Exprs) != 1 {
+			err = errors.New("`Not` requires exactly one expression")
+			return
+		}
+		sqlText, values, err = mp.parseCondExpr(id, expr.Exprs[0])
+		if err != nil {
+			return
+		}
+		sqlText = fmt.Sprintf("NOT (%s)", sqlText)
+		return
+	}
+	if expr.Field == "" {
+		err = errors.New("`Field` can't be empty")
+		return
+	}
+	field, err := id.name(expr.Field)
+	if err != nil {
+		return
+	}
+	switch expr.Op {
+	case dal.OpEq, dal.OpNe, dal.OpGt, dal.OpGe, dal.OpLt, dal.OpLe, dal.OpLike, dal.OpNotLike:
+		if len(expr.Values) != 1 {
+			err = fmt.Errorf("`%s` requires exactly one value", expr.Field)
+			return
+		}
+		if expr.Values[0] == nil && expr.Op == dal.OpEq {
+			sqlText = fmt.Sprintf("%s IS NULL", field)
+			return
+		} else if expr.Values[0] == nil && expr.Op == dal.OpNe {
+			sqlText = fmt.Sprintf("%s IS NOT NULL", field)
+			return
+		}
+		sqlText = fmt.Sprintf("%s %s ?", field, compareOps[expr.Op])
+		values = expr.Values
+	case dal.OpIn, dal.OpNotIn:
+		inValues := dal.ExpandValues(expr.Values)
+		if len(inValues) == 0 {
+			if expr.Op == dal.OpIn {
+				sqlText = "1=0"
+			} else {
+				sqlText = "1=1"
+			}
+			return
+		}
+		op := "IN"
+		if expr.Op == dal.OpNotIn {
+			op = "NOT IN"
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(inValues)), ",")
+		sqlText = fmt.Sprintf("%s %s (%s)", field, op, placeholders)
+		values = inValues
+	case dal.OpBetween:
+		if len(expr.Values) != 2 {
+			err = fmt.Errorf("`%s` requires exactly two values", expr.Field)
+			return
+		}
+		sqlText = fmt.Sprintf("%s BETWEEN ? AND ?", field)
+		values = expr.Values
+	case dal.OpIsNull:
+		sqlText = fmt.Sprintf("%s IS NULL", field)
+	case dal.OpIsNotNull:
+		sqlText = fmt.Sprintf("%s IS NOT NULL", field)
+	default:
+		err = errors.New("The unknown `CondOp`")
+	}
+	return
+}
+
+// sqlStmt 待执行的SQL语句及参数
+type sqlStmt struct {
+	text   string
+	values []interface{}
+}
+
+// buildQuerySQL 解析查询实体，按时间分表的逻辑表替换为条件时间范围内的物理表
+func (mp *MysqlProvider) buildQuerySQL(ctx context.Context, entity dal.QueryEntity) ([]sqlStmt, error) {
+	tables, err := mp.queryTables(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return mp.parseQuerySQL(entity, tables...)
+}
+
+// parseQuerySQL 解析查询实体，返回数据查询语句，分页查询时第二条为总数查询语句
+// tables为逻辑表对应的物理表，多个物理表时使用UNION ALL合并
+func (mp *MysqlProvider) parseQuerySQL(entity dal.QueryEntity, tables ...string) (stmts []sqlStmt, err error) {
+	if entity.FieldsSelect == "" && len(entity.SelectExprs) == 0 {
 		entity.FieldsSelect = "*"
 	}
-	condSQL, condValues, _ := mp.parseCondition(entity.Condition)
+	id := identifier{}
+	var fields []string
+	if entity.FieldsSelect != "" {
+		var fieldsSQL string
+		fieldsSQL, err = id.fields(entity.FieldsSelect)
+		if err != nil {
+			return
+		}
+		fields = append(fields, fieldsSQL)
+	}
+	// 查询表达式原样写入，参数位于条件参数之前
+	var selectValues []interface{}
+	for _, expr := range entity.SelectExprs {
+		fields = append(fields, expr.SQL)
+		selectValues = append(selectValues, expr.Values...)
+	}
+	fieldsSQL := strings.Join(fields, ",")
+	condSQL, condValues, err := mp.parseQueryClause(id, entity.Condition, "WHERE")
+	if err != nil {
+		return
+	}
+	havingSQL, havingValues, err := mp.parseQueryClause(id, entity.Having, "HAVING")
+	if err != nil {
+		return
+	}
+
+	if entity.ResultType == dal.QCursor {
+		if entity.Condition.CType == dal.COND_CV && condSQL != "" {
+			err = errors.New("Cursor query does not support `COND_CV` condition")
+			return
+		}
+		var (
+			cursorSQL    string
+			cursorValues []interface{}
+		)
+		cursorSQL, cursorValues, entity.OrderBy, err = mp.parseCursor(id, entity.CursorParam)
+		if err != nil {
+			return
+		}
+		if cursorSQL != "" {
+			if condSQL == "" {
+				condSQL = fmt.Sprintf("WHERE %s", cursorSQL)
+			} else {
+				condSQL = fmt.Sprintf("%s AND %s", condSQL, cursorSQL)
+			}
+			condValues = append(condValues, cursorValues...)
+		}
+	}
+
+	var (
+		tableSQL    string
+		tableValues []interface{}
+	)
+	if len(tables) > 1 && len(entity.Joins) == 0 && condSQL != "" && entity.Condition.CType != dal.COND_CV {
+		// 没有连接查询时将条件下推到每个物理表的查询中
+		tableSQL, tableValues, err = parseUnionSQL(id, entity, tables, condSQL, condValues)
+		condSQL, condValues = "", nil
+	} else {
+		tableSQL, tableValues, err = mp.parseTableSQL(id, entity, tables...)
+	}
+	if err != nil {
+		return
+	}
+	condValues = append(tableValues, condValues...)
+
+	fromSQL := joinSQL("FROM", tableSQL, condSQL)
+	baseSQL := joinSQL("SELECT", fieldsSQL, fromSQL)
+	if len(entity.GroupBy) > 0 {
+		var groupBy []string
+		groupBy, err = id.names(entity.GroupBy)
+		if err != nil {
+			return
+		}
+		baseSQL = joinSQL(baseSQL, "GROUP BY", strings.Join(groupBy, ","))
+	}
+	baseSQL = joinSQL(baseSQL, havingSQL)
+	baseValues := append(append(selectValues, condValues...), havingValues...)
+
+	querySQL, queryValues := baseSQL, baseValues
+	if len(entity.OrderBy) > 0 {
+		orders := make([]string, len(entity.OrderBy))
+		for i, item := range entity.OrderBy {
+			if item.Expr != nil {
+				orders[i] = item.Expr.SQL
+				queryValues = append(append([]interface{}{}, queryValues...), item.Expr.Values...)
+			} else if orders[i], err = id.name(item.Field); err != nil {
+				return
+			}
+			if item.Desc {
+				orders[i] += " DESC"
+			}
+		}
+		querySQL = joinSQL(querySQL, "ORDER BY", strings.Join(orders, ","))
+	}
+	// 原始SQL条件中可能包含ORDER BY/LIMIT，此时使用子查询限制行数
+	raw := entity.Condition.CType == dal.COND_CV && entity.Condition.Condition != ""
+	rawOrder := raw && rawOrderRegexp.MatchString(entity.Condition.Condition)
+	if err = checkRawClause(entity, rawOrder, raw && rawLimitRegexp.MatchString(entity.Condition.Condition)); err != nil {
+		return
+	}
 
-	querySQL := fmt.Sprintf("SELECT %s FROM %s %s", entity.FieldsSelect, entity.Table, condSQL)
 	switch entity.ResultType {
 	case dal.QSingle:
-		sqlText = append(sqlText, fmt.Sprintf("SELECT * FROM (%s) AS NewTable LIMIT 1", querySQL))
+		stmts = append(stmts, sqlStmt{limitSQL(querySQL, raw, entity.Offset, 1), queryValues})
 	case dal.QPager:
 		pageSize := entity.PagerParam.PageSize
+		if pageSize <= 0 {
+			pageSize = 15
+		}
 		pageIndex := entity.PagerParam.PageIndex
-		sqlText = append(sqlText, fmt.Sprintf("SELECT * FROM (%s) AS NewTable LIMIT %d,%d", querySQL, (pageIndex-1)*pageSize, pageSize))
-		sqlText = append(sqlText, fmt.Sprintf("SELECT COUNT(*) 'Count' FROM %s %s", entity.Table, condSQL))
+		if pageIndex <= 0 {
+			pageIndex = 1
+		}
+		stmts = append(stmts, sqlStmt{limitSQL(querySQL, raw, (pageIndex-1)*pageSize, pageSize), queryValues})
+		// 原始条件中的ORDER BY只保留在子查询中
+		if len(entity.GroupBy) > 0 || havingSQL != "" || rawOrder {
+			stmts = append(stmts, sqlStmt{fmt.Sprintf("SELECT COUNT(*) 'Count' FROM (%s) AS NewTable", baseSQL), baseValues})
+		} else {
+			stmts = append(stmts, sqlStmt{joinSQL("SELECT COUNT(*) 'Count'", fromSQL), condValues})
+		}
+	case dal.QCursor:
+		// 多查询一行用于判断是否还有下一页
+		stmts = append(stmts, sqlStmt{limitSQL(querySQL, false, 0, entity.CursorParam.PageSize()+1), queryValues})
 	default:
-		sqlText = append(sqlText, querySQL)
+		if entity.Limit > 0 {
+			querySQL = limitSQL(querySQL, raw, entity.Offset, entity.Limit)
+		} else if entity.Offset > 0 {
+			querySQL = limitSQL(querySQL, raw, entity.Offset, -1)
+		}
+		stmts = append(stmts, sqlStmt{querySQL, queryValues})
 	}
-	values = append(values, condValues...)
 
 	return
 }
 
-func (mp *mysqlProvider) parseQueryRows(rows *sql.Rows) (datas []map[string]string, err error) {
-	columns, err := rows.Columns()
-	if err != nil {
+var (
+	rawOrderRegexp = regexp.MustCompile(`(?i)\bORDER\s+BY\b`)
+	rawLimitRegexp = regexp.MustCompile(`(?i)\bLIMIT\b`)
+)
+
+// checkRawClause 检查原始SQL条件中的ORDER BY/LIMIT是否与结构化的排序及分页同时使用
+// 同时使用时生成的语句包含重复的子句(或者总数与分页不一致)，返回错误
+func checkRawClause(entity dal.QueryEntity, rawOrder, rawLimit bool) error {
+	if rawOrder && len(entity.OrderBy) > 0 {
+		return errors.New("`OrderBy` can't be used with `COND_CV` condition containing ORDER BY")
+	}
+	if rawLimit && (entity.Limit > 0 || entity.Offset > 0 || entity.ResultType == dal.QPager) {
+		return errors.New("`Limit`, `Offset` and pager can't be used with `COND_CV` condition containing LIMIT")
+	}
+	return nil
+}
+
+// parseCursor 解析游标分页参数，返回游标条件及排序字段
+// 向后翻页使用 (k1,k2) > (?,?) ORDER BY k1,k2，向前翻页时比较符与排序方向取反
+func (mp *MysqlProvider) parseCursor(id identifier, param dal.CursorParam) (sqlText string, values []interface{}, orderBy []dal.OrderField, err error) {
+	keys := param.Keys
+	if len(keys) == 0 {
+		err = errors.New("`Keys` can't be empty")
 		return
 	}
-	var (
-		l = len(columns)
-	)
-	scanValues := make([]interface{}, l)
-	scanArgs := make([]interface{}, l)
-	for i := 0; i < l; i++ {
-		scanArgs[i] = &scanValues[i]
+	desc := keys[0].Desc
+	for _, key := range keys {
+		if key.Desc != desc {
+			err = errors.New("`Keys` must have the same sort direction")
+			return
+		}
 	}
-	for rows.Next() {
-		if err = rows.Scan(scanArgs...); err != nil {
+	var backward bool
+	if param.Cursor != "" {
+		values, backward, err = dal.DecodeCursor(param.Cursor)
+		if err != nil {
 			return
 		}
-		data := make(map[string]string)
-		for i := 0; i < l; i++ {
-			var value string
-			if scanValues[i] != nil {
-				if v, ok := scanValues[i].([]byte); ok {
-					value = string(v)
-				}
-			}
-			data[columns[i]] = value
+		if len(values) != len(keys) {
+			err = dal.ErrInvalidCursor
+			return
+		}
+	}
+	orderBy = make([]dal.OrderField, len(keys))
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		orderBy[i] = dal.OrderField{Field: key.Field, Desc: desc != backward}
+		fields[i], err = id.name(key.Field)
+		if err != nil {
+			return
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	op := ">"
+	if desc != backward {
+		op = "<"
+	}
+	if len(fields) == 1 {
+		sqlText = fmt.Sprintf("%s %s ?", fields[0], op)
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(fields)), ",")
+	sqlText = fmt.Sprintf("(%s) %s (%s)", strings.Join(fields, ","), op, placeholders)
+	return
+}
+
+var joinTypes = map[dal.JoinType]string{
+	dal.JInner: "INNER JOIN",
+	dal.JLeft:  "LEFT JOIN",
+	dal.JRight: "RIGHT JOIN",
+}
+
+// parseTableSQL 解析查询的表及连接查询，tables不为空时查询逻辑表对应的物理表
+func (mp *MysqlProvider) parseTableSQL(id identifier, entity dal.QueryEntity, tables ...string) (sqlText string, values []interface{}, err error) {
+	if entity.Table == "" {
+		err = errors.New("`Table` can't be empty")
+		return
+	}
+	if len(tables) > 0 {
+		sqlText, _, err = parseUnionSQL(id, entity, tables, "", nil)
+	} else {
+		sqlText, err = id.tableAlias(entity.Table, entity.Alias)
+	}
+	if err != nil {
+		return
+	}
+	for _, join := range entity.Joins {
+		joinType, ok := joinTypes[join.Type]
+		if !ok {
+			err = errors.New("The unknown `JoinType`")
+			return
+		}
+		if join.Table == "" || join.On == "" {
+			err = errors.New("`Join` requires `Table` and `On`")
+			return
+		}
+		var table string
+		table, err = id.tableAlias(join.Table, join.Alias)
+		if err != nil {
+			return
+		}
+		sqlText = fmt.Sprintf("%s %s %s ON %s", sqlText, joinType, table, join.On)
+		values = append(values, join.Values...)
+	}
+	return
+}
+
+// joinSQL 使用空格连接非空的SQL片段
+func joinSQL(items ...string) string {
+	var parts []string
+	for _, item := range items {
+		if item != "" {
+			parts = append(parts, item)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// limitSQL 为查询语句增加行数限制，limit小于0时不限制行数
+func limitSQL(querySQL string, wrap bool, offset, limit int) string {
+	if wrap {
+		querySQL = fmt.Sprintf("SELECT * FROM (%s) AS NewTable", querySQL)
+	}
+	if limit < 0 {
+		return fmt.Sprintf("%s LIMIT %d,18446744073709551615", querySQL, offset)
+	}
+	if offset > 0 {
+		return fmt.Sprintf("%s LIMIT %d,%d", querySQL, offset, limit)
+	}
+	return fmt.Sprintf("%s LIMIT %d", querySQL, limit)
+}
+
+func (mp *MysqlProvider) parseQueryRows(rows *queryRows) (datas []map[string]string, err error) {
+	scanner, err := newRowScanner(rows.Rows, mp.location())
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		data, err := scanner.scan()
+		if err != nil {
+			return nil, err
 		}
 		datas = append(datas, data)
 	}
@@ -141,8 +911,8 @@ func (mp *mysqlProvider) parseQueryRows(rows *sql.Rows) (datas []map[string]stri
 	return
 }
 
-func (mp *mysqlProvider) queryData(query string, values ...interface{}) ([]map[string]string, error) {
-	rows, err := GDB.Query(query, values...)
+func (mp *MysqlProvider) queryData(ctx context.Context, query string, values ...interface{}) ([]map[string]string, error) {
+	rows, err := mp.query(ctx, query, values...)
 	if err != nil {
 		return nil, err
 	}
@@ -156,3 +926,28 @@ func (mp *mysqlProvider) queryData(query string, values ...interface{}) ([]map[s
 	}
 	return datas, nil
 }
+
+// queryTypedData 查询数据，值为列类型对应的Go类型，text为true时时间类型的值保留原始文本(用于解析到输出)
+func (mp *MysqlProvider) queryTypedData(ctx context.Context, text bool, query string, values ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := mp.query(ctx, query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scanner, err := newRowScanner(rows.Rows, mp.location())
+	if err != nil {
+		return nil, err
+	}
+	datas := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		data, err := scanner.scanTyped(text)
+		if err != nil {
+			return nil, err
+		}
+		datas = append(datas, data)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return datas, nil
+}
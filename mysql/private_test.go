@@ -0,0 +1,301 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestParseCondExpr(t *testing.T) {
+	mp := new(MysqlProvider)
+	cond := dal.Where(
+		dal.Gt("Age", 18),
+		dal.In("Sex", []int{1, 2}),
+		dal.Or(dal.Like("StuName", "L%"), dal.IsNull("Memo")),
+		dal.Not(dal.Between("Birthday", "1990-01-01", "1999-12-31")),
+	).Condition
+	sqlText, values, err := mp.parseCondition(identifier{}, cond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSQL := "WHERE (`Age` > ? AND `Sex` IN (?,?) AND (`StuName` LIKE ? OR `Memo` IS NULL) AND NOT (`Birthday` BETWEEN ? AND ?))"
+	if sqlText != expectSQL {
+		t.Errorf("unexpected sql: %s", sqlText)
+	}
+	expectValues := []interface{}{18, 1, 2, "L%", "1990-01-01", "1999-12-31"}
+	if !reflect.DeepEqual(values, expectValues) {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
+
+func TestParseCondExprEmptyIn(t *testing.T) {
+	mp := new(MysqlProvider)
+	sqlText, values, err := mp.parseCondExpr(identifier{}, dal.And(dal.In("ID"), dal.Eq("Memo", nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlText != "(1=0 AND `Memo` IS NULL)" || len(values) != 0 {
+		t.Errorf("unexpected sql: %s %v", sqlText, values)
+	}
+}
+
+func TestParseQuerySQL(t *testing.T) {
+	mp := new(MysqlProvider)
+	entity := dal.NewQueryPagerEntity("student",
+		dal.Where(dal.Gt("Age", 18)).Condition,
+		dal.NewPagerParam(2, 10),
+		"Sex").Entity
+	entity.SelectExprs = []dal.SQLExpr{dal.Expr("SUM(Score > ?) AS Num", 60)}
+	entity.GroupBy = []string{"Sex"}
+	entity.Having = dal.Where(dal.Gt("Num", 1)).Condition
+	entity.OrderBy = []dal.OrderField{dal.Desc("Num"), dal.OrderExpr(dal.Expr("FIELD(Sex,?,?)", "M", "F"), false)}
+	stmts, err := mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSQL := "SELECT `Sex`,SUM(Score > ?) AS Num FROM `student` WHERE (`Age` > ?) GROUP BY `Sex` HAVING (`Num` > ?) ORDER BY `Num` DESC,FIELD(Sex,?,?) LIMIT 10,10"
+	if stmts[0].text != expectSQL {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	if !reflect.DeepEqual(stmts[0].values, []interface{}{60, 18, 1, "M", "F"}) {
+		t.Errorf("unexpected values: %v", stmts[0].values)
+	}
+	expectCount := "SELECT COUNT(*) 'Count' FROM (SELECT `Sex`,SUM(Score > ?) AS Num FROM `student` WHERE (`Age` > ?) GROUP BY `Sex` HAVING (`Num` > ?)) AS NewTable"
+	if stmts[1].text != expectCount {
+		t.Errorf("unexpected count sql: %s", stmts[1].text)
+	}
+	if !reflect.DeepEqual(stmts[1].values, []interface{}{60, 18, 1}) {
+		t.Errorf("unexpected values: %v", stmts[1].values)
+	}
+	// 表达式只能通过SelectExprs及OrderExpr传入，条件字段始终校验
+	entity.Having = dal.Where(dal.Gt("COUNT(*)", 1)).Condition
+	if _, err := mp.parseQuerySQL(entity); err == nil {
+		t.Error("expected error for expression in condition")
+	}
+}
+
+func TestParseQuerySQLLimit(t *testing.T) {
+	mp := new(MysqlProvider)
+	entity := dal.NewQueryEntity("student", dal.QueryCondition{}, "ID", "StuCode")().Entity
+	entity.OrderBy = []dal.OrderField{dal.Asc("ID")}
+	entity.Limit = 10
+	entity.Offset = 20
+	stmts, err := mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "SELECT `ID`,`StuCode` FROM `student` ORDER BY `ID` LIMIT 20,10"; stmts[0].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+}
+
+func TestParseQuerySQLRawClause(t *testing.T) {
+	mp := new(MysqlProvider)
+	entity := dal.NewQueryPagerEntity("student",
+		dal.NewCondition("WHERE StuCode LIKE ? ORDER BY ID", "S-%").Condition,
+		dal.NewPagerParam(2, 10),
+		"StuCode").Entity
+	stmts, err := mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "SELECT * FROM (SELECT `StuCode` FROM `student` WHERE StuCode LIKE ? ORDER BY ID) AS NewTable LIMIT 10,10"; stmts[0].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	if expect := "SELECT COUNT(*) 'Count' FROM (SELECT `StuCode` FROM `student` WHERE StuCode LIKE ? ORDER BY ID) AS NewTable"; stmts[1].text != expect {
+		t.Errorf("unexpected count sql: %s", stmts[1].text)
+	}
+
+	entity.OrderBy = []dal.OrderField{dal.Asc("StuCode")}
+	if _, err := mp.parseQuerySQL(entity); err == nil {
+		t.Error("expected error for OrderBy with raw ORDER BY")
+	}
+
+	entity = dal.NewQueryEntity("student", dal.NewCondition("WHERE Age > ? limit 5", 18).Condition, "*")().Entity
+	if _, err := mp.parseQuerySQL(entity); err != nil {
+		t.Fatal(err)
+	}
+	entity.Limit = 10
+	if _, err := mp.parseQuerySQL(entity); err == nil {
+		t.Error("expected error for Limit with raw LIMIT")
+	}
+	entity.Limit, entity.ResultType = 0, dal.QPager
+	if _, err := mp.parseQuerySQL(entity); err == nil {
+		t.Error("expected error for pager with raw LIMIT")
+	}
+}
+
+func TestParseQuerySQLJoin(t *testing.T) {
+	mp := new(MysqlProvider)
+	entity := dal.NewQueryPagerEntity("student",
+		dal.Where(dal.Eq("c.Grade", 3)).Condition,
+		dal.NewPagerParam(1, 20),
+		"s.StuCode", "s.StuName", "c.ClassName").Entity
+	entity.Alias = "s"
+	entity.Joins = []dal.Join{dal.LeftJoin("class", "c", "s.ClassID=c.ID AND c.Status=?", 1)}
+	stmts, err := mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSQL := "SELECT `s`.`StuCode`,`s`.`StuName`,`c`.`ClassName` FROM `student` AS `s` LEFT JOIN `class` AS `c` ON s.ClassID=c.ID AND c.Status=? WHERE (`c`.`Grade` = ?) LIMIT 20"
+	if stmts[0].text != expectSQL {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	expectCount := "SELECT COUNT(*) 'Count' FROM `student` AS `s` LEFT JOIN `class` AS `c` ON s.ClassID=c.ID AND c.Status=? WHERE (`c`.`Grade` = ?)"
+	if stmts[1].text != expectCount {
+		t.Errorf("unexpected count sql: %s", stmts[1].text)
+	}
+	if !reflect.DeepEqual(stmts[1].values, []interface{}{1, 3}) {
+		t.Errorf("unexpected values: %v", stmts[1].values)
+	}
+}
+
+func TestParseQuerySQLCursor(t *testing.T) {
+	mp := new(MysqlProvider)
+	param := dal.NewCursorParam(dal.EncodeCursor([]interface{}{"2016-10-01", 100}, false), 20, dal.Desc("Birthday"), dal.Desc("ID"))
+	entity := dal.NewQueryCursorEntity("student", dal.Where(dal.Eq("Sex", 1)).Condition, param, "ID", "StuName", "Birthday").Entity
+	stmts, err := mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSQL := "SELECT `ID`,`StuName`,`Birthday` FROM `student` WHERE (`Sex` = ?) AND (`Birthday`,`ID`) < (?,?) ORDER BY `Birthday` DESC,`ID` DESC LIMIT 21"
+	if stmts[0].text != expectSQL {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	if !reflect.DeepEqual(stmts[0].values, []interface{}{1, "2016-10-01", int64(100)}) {
+		t.Errorf("unexpected values: %v", stmts[0].values)
+	}
+
+	entity.CursorParam.Cursor = dal.EncodeCursor([]interface{}{"2016-10-01", 100}, true)
+	stmts, err = mp.parseQuerySQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectSQL = "SELECT `ID`,`StuName`,`Birthday` FROM `student` WHERE (`Sex` = ?) AND (`Birthday`,`ID`) > (?,?) ORDER BY `Birthday`,`ID` LIMIT 21"
+	if stmts[0].text != expectSQL {
+		t.Errorf("unexpected backward sql: %s", stmts[0].text)
+	}
+}
+
+func TestGetBatchInsertSQL(t *testing.T) {
+	mp := &MysqlProvider{config: Config{BatchSize: 2}}
+	rows := []map[string]interface{}{
+		{"StuCode": "S001", "Age": 20},
+		{"StuCode": "S002"},
+		{"StuCode": "S003", "Age": 22},
+	}
+	entity := dal.NewTranBatchAEntity("student", rows).Entity
+	stmts, err := mp.getBatchInsertSQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+	if expect := "INSERT INTO `student`(`Age`,`StuCode`) VALUES(?,?),(DEFAULT,?)"; stmts[0].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	if !reflect.DeepEqual(stmts[0].values, []interface{}{20, "S001", "S002"}) {
+		t.Errorf("unexpected values: %v", stmts[0].values)
+	}
+	if expect := "INSERT INTO `student`(`Age`,`StuCode`) VALUES(?,?)"; stmts[1].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[1].text)
+	}
+}
+
+func TestGetInsertSQLOperate(t *testing.T) {
+	mp := &MysqlProvider{}
+	fields := map[string]interface{}{"StuCode": "S001"}
+	tests := []struct {
+		entity dal.TranEntity
+		sql    string
+		values []interface{}
+	}{
+		{
+			dal.NewTranIgnoreEntity("student", fields).Entity,
+			"INSERT IGNORE INTO `student`(`StuCode`) VALUES(?)",
+			[]interface{}{"S001"},
+		},
+		{
+			dal.NewTranReplaceEntity("student", fields).Entity,
+			"REPLACE INTO `student`(`StuCode`) VALUES(?)",
+			[]interface{}{"S001"},
+		},
+		{
+			dal.NewTranUpsertEntity("student", fields, nil).Entity,
+			"INSERT INTO `student`(`StuCode`) VALUES(?) ON DUPLICATE KEY UPDATE `StuCode`=VALUES(`StuCode`)",
+			[]interface{}{"S001"},
+		},
+		{
+			dal.NewTranUpsertValuesEntity("student", fields, map[string]interface{}{
+				"Age":  dal.Expr("Age+?", 1),
+				"Memo": "dup",
+			}).Entity,
+			"INSERT INTO `student`(`StuCode`) VALUES(?) ON DUPLICATE KEY UPDATE `Age`=Age+?,`Memo`=?",
+			[]interface{}{"S001", 1, "dup"},
+		},
+	}
+	for _, test := range tests {
+		sqlText, values, err := mp.getTranSQL(test.entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlText != test.sql {
+			t.Errorf("unexpected sql: %s", sqlText)
+		}
+		if !reflect.DeepEqual(values, test.values) {
+			t.Errorf("unexpected values: %v", values)
+		}
+	}
+}
+
+func TestResolveInsertID(t *testing.T) {
+	prev := []dal.ExecResult{{RowsAffected: 1, LastInsertId: 10}}
+	values, err := resolveInsertID([]interface{}{"S001", dal.InsertID(0)}, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{"S001", int64(10)}) {
+		t.Errorf("unexpected values: %v", values)
+	}
+	if _, err := resolveInsertID([]interface{}{dal.InsertID(1)}, prev); err == nil {
+		t.Error("expected error for reference to a later entity")
+	}
+	if _, err := resolveInsertID([]interface{}{dal.InsertIDRef{}}, prev); err == nil {
+		t.Error("expected error for zero reference")
+	}
+}
+
+func TestGetTranSQLRaw(t *testing.T) {
+	mp := &MysqlProvider{}
+	entity := dal.NewTranSQLEntity("UPDATE student SET Age=Age+? WHERE ClassID=?", 1, dal.InsertID(0)).Entity
+	sqlText, values, err := mp.getTranSQL(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlText != entity.SQL || len(values) != 2 {
+		t.Errorf("unexpected sql: %s %v", sqlText, values)
+	}
+	if _, _, err := mp.getTranSQL(dal.NewTranSQLEntity("").Entity); err == nil {
+		t.Error("expected error for empty sql")
+	}
+}
+
+func TestGetUpdateSQLSorted(t *testing.T) {
+	mp := new(MysqlProvider)
+	cond := dal.NewFieldsKvCondition(map[string]interface{}{"StuCode": "S001", "ClassID": 1}).Condition
+	entity := dal.NewTranUEntity("student", map[string]interface{}{"StuName": "Lyric", "Age": 26, "Memo": nil}, cond).Entity
+	for i := 0; i < 10; i++ {
+		sqlText, values, err := mp.getTranSQL(entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "UPDATE `student` SET `Age`=?,`Memo`=?,`StuName`=? WHERE `ClassID`=? and `StuCode`=?"; sqlText != expect {
+			t.Fatalf("unexpected sql: %s", sqlText)
+		}
+		if !reflect.DeepEqual(values, []interface{}{26, nil, "Lyric", 1, "S001"}) {
+			t.Fatalf("unexpected values: %v", values)
+		}
+	}
+}
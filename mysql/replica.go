@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/antlinker/go-dal"
+)
+
+// 从库的负载均衡策略
+const (
+	// BalanceRoundRobin 轮询
+	BalanceRoundRobin = "roundrobin"
+	// BalanceRandom 随机
+	BalanceRandom = "random"
+	// BalanceLeastConn 最少使用中的连接
+	BalanceLeastConn = "leastconn"
+)
+
+// balancer 从多个从库中选择一个
+type balancer func(replicas []*sql.DB, seq *uint64) *sql.DB
+
+var balancers = map[string]balancer{
+	BalanceRoundRobin: roundRobin,
+	BalanceRandom:     random,
+	BalanceLeastConn:  leastConn,
+}
+
+func roundRobin(replicas []*sql.DB, seq *uint64) *sql.DB {
+	n := atomic.AddUint64(seq, 1)
+	return replicas[(n-1)%uint64(len(replicas))]
+}
+
+func random(replicas []*sql.DB, _ *uint64) *sql.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+func leastConn(replicas []*sql.DB, _ *uint64) *sql.DB {
+	selected := replicas[0]
+	inUse := selected.Stats().InUse
+	for _, replica := range replicas[1:] {
+		if v := replica.Stats().InUse; v < inUse {
+			selected, inUse = replica, v
+		}
+	}
+	return selected
+}
+
+type replicaKey struct{}
+
+// withReplica 选择从库并记录在上下文中，使用该上下文的多条查询(例如分页的总数及数据查询)在同一从库中执行
+func (mp *MysqlProvider) withReplica(ctx context.Context) context.Context {
+	if mp.tx != nil || len(mp.replicas) == 0 || dal.IsPrimary(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, replicaKey{}, balancers[mp.config.Balance](mp.replicas, mp.replicaSeq))
+}
+
+// pinnedReplica 获取上下文中记录的从库，不属于当前Provider时返回nil
+func (mp *MysqlProvider) pinnedReplica(ctx context.Context) *sql.DB {
+	db, _ := ctx.Value(replicaKey{}).(*sql.DB)
+	for _, replica := range mp.replicas {
+		if db != nil && db == replica {
+			return db
+		}
+	}
+	return nil
+}
+
+var lockingReadRegexp = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b`)
+
+// isLockingRead 是否为加锁读(SELECT ... FOR UPDATE/FOR SHARE/LOCK IN SHARE MODE)
+// 从库中的锁不能保护主库的数据，加锁读始终在主库中执行
+func isLockingRead(query string) bool {
+	return lockingReadRegexp.MatchString(query)
+}
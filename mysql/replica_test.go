@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestReader(t *testing.T) {
+	var dbs []*sql.DB
+	for i := 0; i < 3; i++ {
+		db, err := sql.Open("dal-fake", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+	mp := &MysqlProvider{
+		config:     Config{Balance: BalanceRoundRobin},
+		db:         dbs[0],
+		replicas:   dbs[1:],
+		replicaSeq: new(uint64),
+	}
+	ctx := context.Background()
+	for i, expect := range []*sql.DB{dbs[1], dbs[2], dbs[1]} {
+		if _, db := mp.reader(ctx, "SELECT 1"); db != expect {
+			t.Errorf("%d: unexpected replica", i)
+		}
+	}
+	if _, db := mp.reader(dal.WithPrimary(ctx), "SELECT 1"); db != dbs[0] {
+		t.Error("expected primary with WithPrimary")
+	}
+	mp.config.Balance = BalanceLeastConn
+	if _, db := mp.reader(ctx, "SELECT 1"); db != dbs[1] {
+		t.Error("expected first idle replica")
+	}
+}
+
+func TestWithReplica(t *testing.T) {
+	var dbs []*sql.DB
+	for i := 0; i < 3; i++ {
+		db, err := sql.Open("dal-fake", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+	mp := &MysqlProvider{
+		config:     Config{Balance: BalanceRoundRobin},
+		db:         dbs[0],
+		replicas:   dbs[1:],
+		replicaSeq: new(uint64),
+	}
+	ctx := mp.withReplica(context.Background())
+	_, pinned := mp.reader(ctx, "SELECT 1")
+	for i := 0; i < 3; i++ {
+		if _, db := mp.reader(ctx, "SELECT 1"); db != pinned {
+			t.Errorf("%d: expected pinned replica", i)
+		}
+	}
+	if _, db := mp.reader(dal.WithPrimary(ctx), "SELECT 1"); db != dbs[0] {
+		t.Error("expected primary with WithPrimary")
+	}
+	// 其他Provider记录的从库不使用
+	other := &MysqlProvider{config: Config{Balance: BalanceRoundRobin}, db: dbs[0], replicas: []*sql.DB{dbs[2]}, replicaSeq: new(uint64)}
+	if _, db := other.reader(context.WithValue(ctx, replicaKey{}, dbs[1]), "SELECT 1"); db != dbs[2] {
+		t.Error("expected own replica")
+	}
+}
+
+func TestReaderLockingRead(t *testing.T) {
+	var dbs []*sql.DB
+	for i := 0; i < 2; i++ {
+		db, err := sql.Open("dal-fake", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		dbs = append(dbs, db)
+	}
+	mp := &MysqlProvider{
+		config:     Config{Balance: BalanceRoundRobin},
+		db:         dbs[0],
+		replicas:   dbs[1:],
+		replicaSeq: new(uint64),
+	}
+	// 已记录从库的上下文中加锁读同样使用主库
+	ctx := mp.withReplica(context.Background())
+	for _, query := range []string{
+		"SELECT * FROM `student` WHERE `ID` = ? FOR UPDATE",
+		"SELECT * FROM `student` WHERE `ID` = ? for share nowait",
+		"SELECT * FROM `student` WHERE `ID` = ?\nLOCK IN SHARE MODE",
+	} {
+		if _, db := mp.reader(ctx, query); db != dbs[0] {
+			t.Errorf("expected primary: %s", query)
+		}
+	}
+	if _, db := mp.reader(ctx, "SELECT * FROM `student` WHERE `ID` = ?"); db != dbs[1] {
+		t.Error("expected replica")
+	}
+}
@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/antlinker/go-dal"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// 可重试的mysql错误码
+const (
+	ErrNumLockWaitTimeout = 1205
+	ErrNumDeadlock        = 1213
+)
+
+// RetryPolicy 事务重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最大执行次数(默认1，不重试)
+	MaxAttempts int `json:"maxattempts"`
+	// Backoff 首次重试前的等待时间，之后每次翻倍(默认20毫秒)
+	Backoff time.Duration `json:"backoff"`
+	// MaxBackoff 最大等待时间(默认1秒)
+	MaxBackoff time.Duration `json:"maxbackoff"`
+}
+
+func (rp RetryPolicy) normalize() RetryPolicy {
+	if rp.MaxAttempts <= 0 {
+		rp.MaxAttempts = 1
+	}
+	if rp.Backoff <= 0 {
+		rp.Backoff = DefaultRetryBackoff
+	}
+	if rp.MaxBackoff <= 0 {
+		rp.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if rp.MaxBackoff < rp.Backoff {
+		rp.MaxBackoff = rp.Backoff
+	}
+	return rp
+}
+
+// wait 获取第attempt次重试前的等待时间（指数退避，并在后半区间随机抖动）
+func (rp RetryPolicy) wait(attempt int) time.Duration {
+	d := rp.Backoff
+	for i := 1; i < attempt && d < rp.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > rp.MaxBackoff {
+		d = rp.MaxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// SetRetryPolicy 设置事务重试策略，可以在执行操作时并发调用
+func (mp *MysqlProvider) SetRetryPolicy(policy RetryPolicy) {
+	if mp.retry == nil {
+		mp.retry = new(atomic.Value)
+	}
+	mp.retry.Store(policy.normalize())
+}
+
+// retryPolicy 获取事务重试策略
+func (mp *MysqlProvider) retryPolicy() RetryPolicy {
+	if mp.retry != nil {
+		if policy, ok := mp.retry.Load().(RetryPolicy); ok {
+			return policy
+		}
+	}
+	return mp.config.Retry.normalize()
+}
+
+// IsRetryable 检查错误是否为死锁或锁等待超时
+func IsRetryable(err error) bool {
+	var mErr *mysqldriver.MySQLError
+	if !errors.As(err, &mErr) {
+		return false
+	}
+	return mErr.Number == ErrNumDeadlock || mErr.Number == ErrNumLockWaitTimeout
+}
+
+// withRetry 执行fn，遇到死锁或锁等待超时时按重试策略重新执行
+// 处于外部事务中时不重试（整个外部事务已被数据库回滚）
+func (mp *MysqlProvider) withRetry(ctx context.Context, fn func() error) error {
+	policy := mp.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || mp.tx != nil || attempt >= policy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+		mp.log(ctx, dal.LogEntry{
+			Level:   dal.LevelWarn,
+			Message: fmt.Sprintf("Retry(%d/%d)", attempt, policy.MaxAttempts-1),
+			Err:     err,
+		})
+		timer := time.NewTimer(policy.wait(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antlinker/go-dal"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+func TestIsRetryable(t *testing.T) {
+	if !IsRetryable(&mysqldriver.MySQLError{Number: ErrNumDeadlock}) {
+		t.Error("deadlock should be retryable")
+	}
+	if !IsRetryable(fmt.Errorf("exec: %w", &mysqldriver.MySQLError{Number: ErrNumLockWaitTimeout})) {
+		t.Error("wrapped lock wait timeout should be retryable")
+	}
+	if IsRetryable(&mysqldriver.MySQLError{Number: 1062}) {
+		t.Error("duplicate entry should not be retryable")
+	}
+}
+
+func TestRetryPolicyWait(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}.normalize()
+	for attempt := 1; attempt <= 5; attempt++ {
+		d := policy.wait(attempt)
+		if d < 5*time.Millisecond || d > policy.MaxBackoff {
+			t.Errorf("attempt %d: unexpected wait %s", attempt, d)
+		}
+	}
+}
+
+func TestExecTransRetry(t *testing.T) {
+	db, r := openRecorder(t)
+	r.failOn = "UPDATE a"
+	r.failErr = &mysqldriver.MySQLError{Number: ErrNumDeadlock}
+	r.failTimes = 1
+	var entries []dal.LogEntry
+	mp := &MysqlProvider{db: db}
+	mp.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+	mp.SetLogger(dal.LoggerFunc(func(ctx context.Context, entry dal.LogEntry) {
+		entries = append(entries, entry)
+	}))
+	entities := []dal.TranEntity{dal.NewTranSQLEntity("UPDATE a SET x=?", 1).Entity}
+	if result := mp.ExecTrans(entities); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	expect := []string{"BEGIN", "UPDATE a SET x=?", "ROLLBACK", "BEGIN", "UPDATE a SET x=?", "COMMIT"}
+	if !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+	// 未设置IsPrint时也记录重试
+	if len(entries) != 1 || entries[0].Level != dal.LevelWarn || entries[0].Message != "Retry(1/2)" {
+		t.Errorf("unexpected log entries: %+v", entries)
+	}
+
+	// 达到MaxAttempts后返回错误
+	r.reset()
+	r.failTimes, r.failed = 0, 0
+	result := mp.ExecTrans(entities)
+	if !IsRetryable(result.Error) {
+		t.Errorf("expected deadlock error, got %v", result.Error)
+	}
+	if begins := countStatements(r.statements(), "BEGIN"); begins != 3 {
+		t.Errorf("expected 3 attempts, got %d", begins)
+	}
+}
+
+func TestExecTransRetryCancel(t *testing.T) {
+	db, r := openRecorder(t)
+	r.failOn = "UPDATE a"
+	r.failErr = &mysqldriver.MySQLError{Number: ErrNumLockWaitTimeout}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mp := &MysqlProvider{db: db}
+	mp.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Minute})
+	// 记录重试日志时取消，等待重试期间应立即返回
+	mp.SetLogger(dal.LoggerFunc(func(context.Context, dal.LogEntry) {
+		cancel()
+	}))
+	start := time.Now()
+	result := mp.ExecTransContext(ctx, []dal.TranEntity{dal.NewTranSQLEntity("UPDATE a SET x=?", 1).Entity})
+	if !IsRetryable(result.Error) {
+		t.Errorf("expected lock wait timeout, got %v", result.Error)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("retry did not stop after cancel")
+	}
+	if begins := countStatements(r.statements(), "BEGIN"); begins != 1 {
+		t.Errorf("expected 1 attempt, got %d", begins)
+	}
+}
+
+func countStatements(statements []string, query string) (n int) {
+	for _, s := range statements {
+		if s == query {
+			n++
+		}
+	}
+	return
+}
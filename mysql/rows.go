@@ -0,0 +1,213 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antlinker/go-dal"
+	"github.com/antlinker/go-dal/utils"
+)
+
+// rowScanner 读取*sql.Rows的当前行数据
+type rowScanner struct {
+	rows       *sql.Rows
+	columns    []string
+	types      []string
+	scanValues []interface{}
+	scanArgs   []interface{}
+	// loc 解析时间列使用的时区
+	loc *time.Location
+}
+
+func newRowScanner(rows *sql.Rows, loc *time.Location) (*rowScanner, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	l := len(columnTypes)
+	scanner := &rowScanner{
+		rows:       rows,
+		columns:    make([]string, l),
+		types:      make([]string, l),
+		scanValues: make([]interface{}, l),
+		scanArgs:   make([]interface{}, l),
+		loc:        loc,
+	}
+	for i, ct := range columnTypes {
+		scanner.columns[i] = ct.Name()
+		scanner.types[i] = columnType(ct)
+	}
+	for i := 0; i < l; i++ {
+		scanner.scanArgs[i] = &scanner.scanValues[i]
+	}
+	return scanner, nil
+}
+
+var (
+	nullIntType   = reflect.TypeOf(sql.NullInt64{})
+	nullFloatType = reflect.TypeOf(sql.NullFloat64{})
+	nullTimeType  = reflect.TypeOf(sql.NullTime{})
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+// columnType 获取列的数据库类型，驱动未提供类型名称时根据ScanType推断
+func columnType(ct *sql.ColumnType) string {
+	if name := ct.DatabaseTypeName(); name != "" {
+		return strings.ToUpper(name)
+	}
+	scanType := ct.ScanType()
+	if scanType == nil {
+		return ""
+	}
+	switch scanType {
+	case nullIntType:
+		return "BIGINT"
+	case nullFloatType:
+		return "DOUBLE"
+	case nullTimeType, timeType:
+		return "DATETIME"
+	}
+	switch scanType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "UNSIGNED BIGINT"
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE"
+	}
+	return ""
+}
+
+// scan 读取当前行，所有的值转换为字符串（NULL转换为空字符串）
+func (rs *rowScanner) scan() (map[string]string, error) {
+	if err := rs.rows.Scan(rs.scanArgs...); err != nil {
+		return nil, err
+	}
+	data := make(map[string]string)
+	for i, l := 0, len(rs.columns); i < l; i++ {
+		data[rs.columns[i]] = dal.FormatValue(rs.scanValues[i])
+	}
+	return data, nil
+}
+
+// scanTyped 读取当前行，根据列类型转换为对应的Go类型
+// (int64,uint64,float64,time.Time,string,[]byte,NULL为nil)
+// text为true时时间类型的值使用utils.TextValue保留原始文本，解析到字符串时与scan的结果一致
+func (rs *rowScanner) scanTyped(text bool) (map[string]interface{}, error) {
+	if err := rs.rows.Scan(rs.scanArgs...); err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{})
+	for i, l := 0, len(rs.columns); i < l; i++ {
+		value := convertValue(rs.scanValues[i], rs.types[i], rs.loc)
+		if b, ok := rs.scanValues[i].([]byte); ok && text {
+			if _, ok := value.(time.Time); ok {
+				value = utils.TextValue{Value: value, Text: string(b)}
+			}
+		}
+		data[rs.columns[i]] = value
+	}
+	return data, nil
+}
+
+// convertValue 将驱动返回的[]byte按数据库列类型转换为对应的Go类型
+// DECIMAL类型保留为字符串以避免精度丢失，无法识别的类型转换为字符串
+// 时间类型使用loc时区解析(与驱动parseTime=true时一致)
+func convertValue(value interface{}, dbType string, loc *time.Location) interface{} {
+	b, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	dbType = strings.TrimPrefix(dbType, "UNSIGNED ")
+	switch dbType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		if v, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return v
+		}
+		if v, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+			return v
+		}
+	case "FLOAT", "DOUBLE", "REAL":
+		if v, err := strconv.ParseFloat(string(b), 64); err == nil {
+			return v
+		}
+	case "DATETIME", "TIMESTAMP", "DATE":
+		s := string(b)
+		if strings.HasPrefix(s, "0000-00-00") {
+			return time.Time{}
+		}
+		if v, err := time.ParseInLocation(dal.TimeFormat, s, loc); err == nil {
+			return v
+		}
+		if v, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+			return v
+		}
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return b
+	}
+	return string(b)
+}
+
+// mysqlRows 提供逐行读取查询结果
+type mysqlRows struct {
+	rows    *queryRows
+	scanner *rowScanner
+}
+
+func (r *mysqlRows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r *mysqlRows) Scan(output interface{}) error {
+	data, err := r.scanner.scanTyped(true)
+	if err != nil {
+		return err
+	}
+	return utils.NewDecoder(&data).Decode(output)
+}
+
+func (r *mysqlRows) Err() error {
+	return r.rows.Err()
+}
+
+func (r *mysqlRows) Close() error {
+	return r.rows.Close()
+}
+
+func (mp *MysqlProvider) QueryRows(entity dal.QueryEntity) (dal.Rows, error) {
+	return mp.QueryRowsContext(context.Background(), entity)
+}
+
+func (mp *MysqlProvider) QueryRowsContext(ctx context.Context, entity dal.QueryEntity) (dal.Rows, error) {
+	ctx = withCall(ctx, "QueryRows", entity)
+	if entity.ResultType != dal.QList {
+		entity.ResultType = dal.QList
+	}
+	stmts, err := mp.buildQuerySQL(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return mp.QueryRowsWithSQLContext(ctx, stmts[0].text, stmts[0].values...)
+}
+
+func (mp *MysqlProvider) QueryRowsWithSQL(sql string, values ...interface{}) (dal.Rows, error) {
+	return mp.QueryRowsWithSQLContext(context.Background(), sql, values...)
+}
+
+func (mp *MysqlProvider) QueryRowsWithSQLContext(ctx context.Context, sql string, values ...interface{}) (dal.Rows, error) {
+	ctx = withCall(ctx, "QueryRowsWithSQL", nil)
+	rows, err := mp.query(ctx, sql, values...)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := newRowScanner(rows.Rows, mp.location())
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return &mysqlRows{rows: rows, scanner: scanner}, nil
+}
@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestConvertValue(t *testing.T) {
+	if v := convertValue([]byte("42"), "BIGINT", time.UTC); v != int64(42) {
+		t.Errorf("unexpected int value: %#v", v)
+	}
+	if v := convertValue([]byte("18446744073709551615"), "UNSIGNED BIGINT", time.UTC); v != uint64(18446744073709551615) {
+		t.Errorf("unexpected uint value: %#v", v)
+	}
+	if v := convertValue([]byte("1.5"), "DOUBLE", time.UTC); v != 1.5 {
+		t.Errorf("unexpected float value: %#v", v)
+	}
+	if v := convertValue([]byte("12.30"), "DECIMAL", time.UTC); v != "12.30" {
+		t.Errorf("unexpected decimal value: %#v", v)
+	}
+	expect := time.Date(2016, 10, 13, 8, 30, 0, 0, time.UTC)
+	if v, ok := convertValue([]byte("2016-10-13 08:30:00"), "DATETIME", time.UTC).(time.Time); !ok || !v.Equal(expect) {
+		t.Errorf("unexpected time value: %#v", v)
+	}
+	if v := convertValue(nil, "VARCHAR", time.UTC); v != nil {
+		t.Errorf("unexpected null value: %#v", v)
+	}
+	if v := convertValue([]byte("Lyric"), "", time.UTC); v != "Lyric" {
+		t.Errorf("unexpected string value: %#v", v)
+	}
+}
+
+func TestScanTypedColumns(t *testing.T) {
+	db, r := openRecorder(t)
+	r.columns = []fakeColumn{
+		{name: "ID", scanType: reflect.TypeOf(int64(0))},
+		{name: "Amount", typeName: "DECIMAL"},
+		{name: "Score", scanType: reflect.TypeOf(sql.NullFloat64{})},
+		{name: "CreatedAt", scanType: reflect.TypeOf(sql.NullTime{})},
+		{name: "Name", typeName: "VARCHAR"},
+	}
+	r.rows = [][]driver.Value{
+		{[]byte("42"), []byte("12.30"), []byte("1.5"), []byte("2016-10-13 08:30:00"), []byte("Lyric")},
+	}
+	mp := &MysqlProvider{db: db}
+	var data []map[string]interface{}
+	if err := mp.AssignListWithSQL("SELECT * FROM `student`", nil, &data); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"ID":        int64(42),
+		"Amount":    "12.30",
+		"Score":     1.5,
+		"CreatedAt": time.Date(2016, 10, 13, 8, 30, 0, 0, time.UTC),
+		"Name":      "Lyric",
+	}
+	if len(data) != 1 || !reflect.DeepEqual(data[0], expect) {
+		t.Errorf("unexpected data: %#v", data)
+	}
+}
+
+func TestAssignTimeText(t *testing.T) {
+	db, r := openRecorder(t)
+	r.columns = []fakeColumn{
+		{name: "Birthday", typeName: "DATE"},
+		{name: "CreatedAt", typeName: "DATETIME"},
+	}
+	r.rows = [][]driver.Value{
+		{[]byte("2016-10-13"), []byte("2016-10-13 08:30:00.123456")},
+	}
+	mp := &MysqlProvider{db: db}
+	expect := map[string]string{"Birthday": "2016-10-13", "CreatedAt": "2016-10-13 08:30:00.123456"}
+	single, err := mp.SingleWithSQL("SELECT * FROM `student`")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(single, expect) {
+		t.Errorf("unexpected single: %v", single)
+	}
+	var data map[string]string
+	if err := mp.AssignSingleWithSQL("SELECT * FROM `student`", nil, &data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, expect) {
+		t.Errorf("unexpected data: %v", data)
+	}
+	var list []map[string]string
+	if err := mp.AssignListWithSQL("SELECT * FROM `student`", nil, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !reflect.DeepEqual(list[0], expect) {
+		t.Errorf("unexpected list: %v", list)
+	}
+	var student struct {
+		Birthday  string
+		CreatedAt time.Time
+		Updated   *string `dal:"CreatedAt"`
+	}
+	if err := mp.AssignSingleWithSQL("SELECT * FROM `student`", nil, &student); err != nil {
+		t.Fatal(err)
+	}
+	if student.Birthday != "2016-10-13" || student.Updated == nil || *student.Updated != expect["CreatedAt"] {
+		t.Errorf("unexpected student: %+v", student)
+	}
+	if !student.CreatedAt.Equal(time.Date(2016, 10, 13, 8, 30, 0, 123456000, time.UTC)) {
+		t.Errorf("unexpected time: %v", student.CreatedAt)
+	}
+}
+
+func TestEachRowClose(t *testing.T) {
+	db, r := openRecorder(t)
+	r.columns = []fakeColumn{{name: "ID", typeName: "BIGINT"}, {name: "Name", typeName: "VARCHAR"}}
+	r.rows = [][]driver.Value{{[]byte("1"), []byte("a")}, {[]byte("2"), []byte("b")}}
+	mp := &MysqlProvider{db: db}
+	errCallback := errors.New("callback error")
+	for i, item := range []struct {
+		fn     func(rows dal.Rows) error
+		expect func(err error) bool
+	}{
+		// ErrStopIterate提前结束遍历，不返回错误
+		{func(rows dal.Rows) error { return dal.ErrStopIterate }, func(err error) bool { return err == nil }},
+		{func(rows dal.Rows) error { return errCallback }, func(err error) bool { return err == errCallback }},
+		{func(rows dal.Rows) error {
+			var id []int
+			return rows.Scan(&id)
+		}, func(err error) bool { return err != nil && err != errCallback }},
+	} {
+		rows, err := mp.QueryRowsWithSQL("SELECT * FROM `student`")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dal.EachRow(rows, item.fn); !item.expect(err) {
+			t.Errorf("unexpected error of case %d: %v", i, err)
+		}
+		if n := r.closedRows(); n != i+1 {
+			t.Errorf("expected rows closed in case %d, closed %d", i, n)
+		}
+		if n := db.Stats().InUse; n != 0 {
+			t.Errorf("expected connection released in case %d, in use %d", i, n)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package mysql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// StmtCacheStats 预处理语句缓存的统计信息
+type StmtCacheStats struct {
+	// Size 当前缓存的语句数量
+	Size int `json:"size"`
+	// Hits 缓存命中次数
+	Hits int64 `json:"hits"`
+	// Misses 缓存未命中次数
+	Misses int64 `json:"misses"`
+}
+
+// stmtCache 以SQL语句为键的预处理语句LRU缓存
+type stmtCache struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	items  map[string]*list.Element
+	hits   int64
+	misses int64
+}
+
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get 获取预处理语句，使用完毕后需要调用release
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[query]; ok {
+		c.hits++
+		c.ll.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[query]; ok {
+		// 其它调用已经缓存了相同的语句
+		stmt.Close()
+		c.ll.MoveToFront(elem)
+		entry := elem.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+	return entry, nil
+}
+
+// release 释放预处理语句，已被淘汰且不再使用的语句将被关闭
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) evict(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*stmtEntry)
+	delete(c.items, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return StmtCacheStats{
+		Size:   c.ll.Len(),
+		Hits:   c.hits,
+		Misses: c.misses,
+	}
+}
+
+// prepared 从db的缓存中获取预处理语句
+// 未启用缓存、事务中、非DML语句、多行INSERT或预处理失败时返回nil，此时直接执行SQL语句
+func (mp *MysqlProvider) prepared(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, func()) {
+	cache, ok := mp.stmts[db]
+	// 事务持有连接，在连接池中预处理需要额外的连接且无法在事务中复用
+	if !ok || mp.tx != nil || !isDML(query) || isMultiRowInsert(query) {
+		return nil, nil
+	}
+	entry, err := cache.get(ctx, db, query)
+	if err != nil {
+		return nil, nil
+	}
+	return entry.stmt, func() {
+		cache.release(entry)
+	}
+}
+
+// isDML 是否为可以缓存的DML语句(SELECT、INSERT、UPDATE、DELETE、REPLACE)
+func isDML(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	if i := strings.IndexAny(query, " \t\r\n"); i > 0 {
+		query = query[:i]
+	}
+	switch strings.ToUpper(query) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return true
+	}
+	return false
+}
+
+// StmtCacheStats 获取预处理语句缓存的统计信息(包含主库及从库)
+func (mp *MysqlProvider) StmtCacheStats() (stats StmtCacheStats) {
+	for _, cache := range mp.stmts {
+		item := cache.stats()
+		stats.Size += item.Size
+		stats.Hits += item.Hits
+		stats.Misses += item.Misses
+	}
+	return
+}
+
+// isMultiRowInsert 是否为多行INSERT(REPLACE)语句
+// 批量新增的语句随行数变化且参数较多，缓存后很少复用并长期占用服务端的预处理语句
+func isMultiRowInsert(query string) bool {
+	query = strings.ToUpper(strings.Join(strings.Fields(query), ""))
+	if !strings.HasPrefix(query, "INSERT") && !strings.HasPrefix(query, "REPLACE") {
+		return false
+	}
+	i := strings.Index(query, "VALUES(")
+	return i >= 0 && strings.Contains(query[i:], "),(")
+}
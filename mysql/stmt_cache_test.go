@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+var fakeClosed int64
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error {
+	atomic.AddInt64(&fakeClosed, 1)
+	return nil
+}
+func (fakeStmt) NumInput() int { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("dal-fake", fakeDriver{})
+}
+
+func TestStmtCache(t *testing.T) {
+	db, err := sql.Open("dal-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mp := &MysqlProvider{db: db, stmts: map[*sql.DB]*stmtCache{db: newStmtCache(2)}}
+	ctx := context.Background()
+	closed := atomic.LoadInt64(&fakeClosed)
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3", "SELECT 2"} {
+		if _, err := mp.exec(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := mp.StmtCacheStats()
+	if stats.Hits != 1 || stats.Misses != 4 || stats.Size != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	// SELECT 2与SELECT 1先后被淘汰
+	if closed := atomic.LoadInt64(&fakeClosed) - closed; closed != 2 {
+		t.Errorf("expected 2 closed statements, got %d", closed)
+	}
+}
+
+func TestStmtCacheSkip(t *testing.T) {
+	db, err := sql.Open("dal-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mp := &MysqlProvider{db: db, stmts: map[*sql.DB]*stmtCache{db: newStmtCache(2)}}
+	ctx := context.Background()
+	for _, query := range []string{
+		"SAVEPOINT dal_sp_1", "CREATE TABLE t(id INT)", "SET NAMES utf8mb4",
+		"INSERT INTO `t`(`a`,`b`) VALUES(?,?),(DEFAULT,?)",
+		"replace into t(a) values (?), (?)",
+	} {
+		if stmt, _ := mp.prepared(ctx, db, query); stmt != nil {
+			t.Errorf("unexpected cached statement: %s", query)
+		}
+	}
+	if stmt, release := mp.prepared(ctx, db, " (SELECT 1) UNION ALL (SELECT 2)"); stmt == nil {
+		t.Error("expected cached statement")
+	} else {
+		release()
+	}
+	// 单行INSERT(包括使用VALUES函数的upsert)可以缓存
+	if stmt, release := mp.prepared(ctx, db, "INSERT INTO `t`(`a`,`b`) VALUES(?,?) ON DUPLICATE KEY UPDATE `a`=VALUES(`a`),`b`=VALUES(`b`)"); stmt == nil {
+		t.Error("expected cached statement")
+	} else {
+		release()
+	}
+	mp.tx = new(sql.Tx)
+	if stmt, _ := mp.prepared(ctx, db, "SELECT 1"); stmt != nil {
+		t.Error("unexpected cached statement in transaction")
+	}
+	if stats := mp.StmtCacheStats(); stats.Size != 2 || stats.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
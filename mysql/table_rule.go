@@ -0,0 +1,446 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antlinker/go-dal"
+)
+
+// 按时间分表的周期
+const (
+	// PeriodDay 按天分表
+	PeriodDay = "day"
+	// PeriodMonth 按月分表
+	PeriodMonth = "month"
+	// PeriodYear 按年分表
+	PeriodYear = "year"
+)
+
+var periodLayouts = map[string]string{
+	PeriodDay:   "20060102",
+	PeriodMonth: "200601",
+	PeriodYear:  "2006",
+}
+
+// tableCacheTTL 已存在的物理表的缓存时间
+const tableCacheTTL = time.Minute
+
+// TableRule 按时间分表的规则，物理表名为：逻辑表名_时间后缀(例如：op_log_202610)
+type TableRule struct {
+	// Table 逻辑表名
+	Table string `json:"table"`
+	// Field 分表的时间字段
+	Field string `json:"field"`
+	// Period 分表周期(day、month、year，默认为month)
+	Period string `json:"period"`
+	// Layout 表名后缀的时间格式(默认按照周期分别为20060102、200601、2006)
+	Layout string `json:"layout"`
+	// Location 计算表名后缀及解析时间字符串使用的时区(例如：Asia/Shanghai，默认使用数据源的loc参数)
+	Location string `json:"location"`
+
+	loc *time.Location
+}
+
+// normalize 校验分表规则并设置默认值，loc为未设置Location时使用的时区(nil为UTC)
+func (r TableRule) normalize(loc *time.Location) (TableRule, error) {
+	if r.Table == "" || r.Field == "" {
+		return r, errors.New("`TableRule` requires `table` and `field`")
+	}
+	if r.Location != "" {
+		var err error
+		if loc, err = time.LoadLocation(r.Location); err != nil {
+			return r, err
+		}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	r.loc = loc
+	if r.Period == "" {
+		r.Period = PeriodMonth
+	}
+	layout, ok := periodLayouts[r.Period]
+	if !ok {
+		return r, fmt.Errorf("The unknown `period`: %s", r.Period)
+	}
+	if r.Layout == "" {
+		r.Layout = layout
+	}
+	return r, nil
+}
+
+// physical 获取时间所在的物理表，按照规则的时区计算表名后缀
+func (r TableRule) physical(t time.Time) string {
+	return r.Table + "_" + t.In(r.loc).Format(r.Layout)
+}
+
+// start 解析物理表名，获取物理表的起始时间
+func (r TableRule) start(table string) (time.Time, bool) {
+	prefix := r.Table + "_"
+	if !strings.HasPrefix(table, prefix) {
+		return time.Time{}, false
+	}
+	suffix := table[len(prefix):]
+	t, err := time.ParseInLocation(r.Layout, suffix, r.loc)
+	if err != nil || t.Format(r.Layout) != suffix {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// next 获取下一个周期的起始时间
+func (r TableRule) next(start time.Time) time.Time {
+	switch r.Period {
+	case PeriodDay:
+		return start.AddDate(0, 0, 1)
+	case PeriodYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// route 根据时间字段的值获取新增数据的物理表
+func (r TableRule) route(fields map[string]interface{}) (string, error) {
+	for k, v := range fields {
+		if !matchField(k, r.Field) {
+			continue
+		}
+		if t, ok := toTime(v, r.loc); ok {
+			return r.physical(t), nil
+		}
+	}
+	return "", fmt.Errorf("`%s` requires a time value of `%s`", r.Table, r.Field)
+}
+
+// filter 获取时间范围内的物理表
+func (r TableRule) filter(tables []string, tr timeRange) []string {
+	var items []string
+	for _, table := range tables {
+		start, ok := r.start(table)
+		if !ok {
+			continue
+		}
+		if tr.hasEnd && start.After(tr.end) ||
+			tr.hasStart && !r.next(start).After(tr.start) {
+			continue
+		}
+		items = append(items, table)
+	}
+	return items
+}
+
+// timeRange 时间字段的取值范围(包含边界)
+type timeRange struct {
+	start, end       time.Time
+	hasStart, hasEnd bool
+}
+
+func (tr *timeRange) lower(t time.Time) {
+	if !tr.hasStart || t.After(tr.start) {
+		tr.start, tr.hasStart = t, true
+	}
+}
+
+func (tr *timeRange) upper(t time.Time) {
+	if !tr.hasEnd || t.Before(tr.end) {
+		tr.end, tr.hasEnd = t, true
+	}
+}
+
+// fieldRange 从条件中获取时间字段的取值范围
+// 支持键值条件以及条件表达式中使用And连接的Eq、Gt、Ge、Lt、Le、Between、In
+func (r TableRule) fieldRange(cond dal.QueryCondition) (tr timeRange) {
+	switch cond.CType {
+	case dal.COND_KV:
+		for k, v := range cond.FieldsKv {
+			if t, ok := toTime(v, r.loc); ok && matchField(k, r.Field) {
+				tr.lower(t)
+				tr.upper(t)
+			}
+		}
+	case dal.COND_EXPR:
+		r.exprRange(cond.Expr, &tr)
+	}
+	return
+}
+
+func (r TableRule) exprRange(expr dal.CondExpr, tr *timeRange) {
+	if expr.Op == dal.OpAnd {
+		for _, item := range expr.Exprs {
+			r.exprRange(item, tr)
+		}
+		return
+	}
+	if !matchField(expr.Field, r.Field) {
+		return
+	}
+	var times []time.Time
+	for _, v := range dal.ExpandValues(expr.Values) {
+		t, ok := toTime(v, r.loc)
+		if !ok {
+			return
+		}
+		times = append(times, t)
+	}
+	switch {
+	case len(times) == 1 && (expr.Op == dal.OpEq || expr.Op == dal.OpGt || expr.Op == dal.OpGe):
+		tr.lower(times[0])
+		if expr.Op == dal.OpEq {
+			tr.upper(times[0])
+		}
+	case len(times) == 1 && (expr.Op == dal.OpLt || expr.Op == dal.OpLe):
+		tr.upper(times[0])
+	case len(times) == 2 && expr.Op == dal.OpBetween:
+		tr.lower(times[0])
+		tr.upper(times[1])
+	case len(times) > 0 && expr.Op == dal.OpIn:
+		min, max := times[0], times[0]
+		for _, t := range times[1:] {
+			if t.Before(min) {
+				min = t
+			}
+			if t.After(max) {
+				max = t
+			}
+		}
+		tr.lower(min)
+		tr.upper(max)
+	}
+}
+
+// matchField 判断字段是否为分表的时间字段(忽略表别名、反引号及大小写)
+func matchField(name, field string) bool {
+	if idx := strings.LastIndex(name, "."); idx > -1 {
+		name = name[idx+1:]
+	}
+	if ident, ok := unquoteIdent(name); ok {
+		name = ident
+	}
+	return strings.EqualFold(name, field)
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// toTime 将time.Time及时间字符串转换为时间，时间字符串使用loc时区解析
+func toTime(value interface{}, loc *time.Location) (time.Time, bool) {
+	var s string
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, true
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return time.Time{}, false
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// tableCache 缓存逻辑表已存在的物理表
+type tableCache struct {
+	mu    sync.Mutex
+	items map[string]tableCacheItem
+}
+
+type tableCacheItem struct {
+	tables  []string
+	expires time.Time
+}
+
+func newTableCache() *tableCache {
+	return &tableCache{items: make(map[string]tableCacheItem)}
+}
+
+func (c *tableCache) get(table string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[table]
+	if !ok || time.Now().After(item.expires) {
+		return nil, false
+	}
+	return item.tables, true
+}
+
+func (c *tableCache) set(table string, tables []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[table] = tableCacheItem{tables: tables, expires: time.Now().Add(tableCacheTTL)}
+}
+
+// touch 写入数据的物理表不在缓存中时(可能是新建的表)清除缓存
+func (c *tableCache) touch(table, physical string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[table]
+	if !ok {
+		return
+	}
+	for _, v := range item.tables {
+		if v == physical {
+			return
+		}
+	}
+	delete(c.items, table)
+}
+
+// existingTables 获取逻辑表已存在的物理表(按照时间排序)
+func (mp *MysqlProvider) existingTables(ctx context.Context, rule TableRule) ([]string, error) {
+	if mp.tableCache != nil {
+		if tables, ok := mp.tableCache.get(rule.Table); ok {
+			return tables, nil
+		}
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, "_", `\_`, "%", `\%`).Replace(rule.Table+"_") + "%"
+	// 使用主库查询，避免从库延迟导致新建的表不可见
+	rows, err := mp.query(dal.WithPrimary(ctx),
+		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME LIKE ?", pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	starts := make(map[string]time.Time)
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		if start, ok := rule.start(table); ok {
+			starts[table] = start
+			tables = append(tables, table)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return starts[tables[i]].Before(starts[tables[j]])
+	})
+	if mp.tableCache != nil {
+		mp.tableCache.set(rule.Table, tables)
+	}
+	return tables, nil
+}
+
+// queryTables 获取查询实体的逻辑表在查询条件时间范围内的物理表，未配置分表规则时返回nil
+func (mp *MysqlProvider) queryTables(ctx context.Context, entity dal.QueryEntity) ([]string, error) {
+	rule, ok := mp.tableRules[entity.Table]
+	if !ok {
+		return nil, nil
+	}
+	existing, err := mp.existingTables(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, fmt.Errorf("No physical table of `%s` exists", rule.Table)
+	}
+	tables := rule.filter(existing, rule.fieldRange(entity.Condition))
+	if len(tables) == 0 {
+		// 时间范围内没有物理表时查询最近的物理表，时间条件保证查询结果为空
+		tables = existing[len(existing)-1:]
+	}
+	return tables, nil
+}
+
+// tranTables 将事务实体路由到物理表，批量新增按照物理表拆分数据
+// 更新及删除操作在条件时间范围内已存在的物理表中分别执行
+func (mp *MysqlProvider) tranTables(ctx context.Context, entity dal.TranEntity) (entities []dal.TranEntity, err error) {
+	rule, ok := mp.tableRules[entity.Table]
+	if !ok || entity.Operate == dal.TSQL {
+		return []dal.TranEntity{entity}, nil
+	}
+	switch entity.Operate {
+	case dal.TA, dal.TAU, dal.TAI, dal.TR:
+		table, err := rule.route(entity.FieldsValue)
+		if err != nil {
+			return nil, err
+		}
+		mp.touchTable(rule, table)
+		entity.Table = table
+		return []dal.TranEntity{entity}, nil
+	case dal.TBA:
+		batches := make(map[string][]map[string]interface{})
+		var tables []string
+		for _, row := range entity.BatchValues {
+			table, err := rule.route(row)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := batches[table]; !ok {
+				tables = append(tables, table)
+			}
+			batches[table] = append(batches[table], row)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			mp.touchTable(rule, table)
+			item := entity
+			item.Table = table
+			item.BatchValues = batches[table]
+			entities = append(entities, item)
+		}
+		return
+	}
+	existing, err := mp.existingTables(ctx, rule)
+	if err != nil {
+		return
+	}
+	for _, table := range rule.filter(existing, rule.fieldRange(entity.Condition)) {
+		item := entity
+		item.Table = table
+		entities = append(entities, item)
+	}
+	return
+}
+
+func (mp *MysqlProvider) touchTable(rule TableRule, table string) {
+	if mp.tableCache != nil {
+		mp.tableCache.touch(rule.Table, table)
+	}
+}
+
+// parseUnionSQL 获取物理表的查询来源，多个物理表时使用UNION ALL合并，别名为逻辑表的别名或表名
+// condSQL不为空时将条件下推到每个物理表的查询中
+func parseUnionSQL(id identifier, entity dal.QueryEntity, tables []string, condSQL string, condValues []interface{}) (sqlText string, values []interface{}, err error) {
+	alias := entity.Alias
+	if alias == "" {
+		alias = entity.Table
+	}
+	alias, err = id.alias(alias)
+	if err != nil {
+		return
+	}
+	if len(tables) == 1 {
+		sqlText = fmt.Sprintf("%s AS %s", quoteIdent(tables[0]), alias)
+		return
+	}
+	branches := make([]string, len(tables))
+	for i, table := range tables {
+		branches[i] = joinSQL("SELECT * FROM", quoteIdent(table), "AS", alias, condSQL)
+		values = append(values, condValues...)
+	}
+	sqlText = fmt.Sprintf("(%s) AS %s", strings.Join(branches, " UNION ALL "), alias)
+	return
+}
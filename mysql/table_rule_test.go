@@ -0,0 +1,177 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antlinker/go-dal"
+)
+
+func newTableRuleProvider(t *testing.T, existing ...string) *MysqlProvider {
+	rule, err := TableRule{Table: "op_log", Field: "CreatedAt"}.normalize(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := &MysqlProvider{
+		tableRules: map[string]TableRule{rule.Table: rule},
+		tableCache: newTableCache(),
+	}
+	mp.tableCache.set(rule.Table, existing)
+	return mp
+}
+
+func TestFieldRange(t *testing.T) {
+	rule, _ := TableRule{Table: "op_log", Field: "CreatedAt"}.normalize(nil)
+	cond := dal.Where(
+		dal.Eq("Action", "login"),
+		dal.Ge("l.`CreatedAt`", "2026-08-15 10:00:00"),
+		dal.Lt("createdat", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)),
+	).Condition
+	tr := rule.fieldRange(cond)
+	if !tr.hasStart || !tr.start.Equal(time.Date(2026, 8, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start: %v", tr.start)
+	}
+	if !tr.hasEnd || !tr.end.Equal(time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected end: %v", tr.end)
+	}
+	tr = rule.fieldRange(dal.Where(dal.Or(dal.Eq("CreatedAt", "2026-08-01"))).Condition)
+	if tr.hasStart || tr.hasEnd {
+		t.Errorf("unexpected range: %v", tr)
+	}
+}
+
+func TestTableRuleFilter(t *testing.T) {
+	mp := newTableRuleProvider(t)
+	rule := mp.tableRules["op_log"]
+	existing := []string{"op_log_202607", "op_log_202608", "op_log_202609", "op_log_202610", "op_log_bak"}
+	tr := rule.fieldRange(dal.Where(dal.Between("CreatedAt", "2026-08-15", "2026-09-30")).Condition)
+	tables := rule.filter(existing, tr)
+	if !reflect.DeepEqual(tables, []string{"op_log_202608", "op_log_202609"}) {
+		t.Errorf("unexpected tables: %v", tables)
+	}
+	tables = rule.filter(existing, rule.fieldRange(dal.Where(dal.Ge("CreatedAt", "2026-10-01")).Condition))
+	if !reflect.DeepEqual(tables, []string{"op_log_202610"}) {
+		t.Errorf("unexpected tables: %v", tables)
+	}
+	if _, err := (TableRule{Table: "op_log", Field: "CreatedAt", Period: "week"}).normalize(nil); err == nil {
+		t.Error("expected error for unknown period")
+	}
+}
+
+func TestQueryTables(t *testing.T) {
+	mp := newTableRuleProvider(t, "op_log_202608", "op_log_202609", "op_log_202610")
+	entity := dal.NewQueryPagerEntity("op_log",
+		dal.Where(dal.Eq("Action", "login"), dal.Ge("CreatedAt", "2026-09-01")).Condition,
+		dal.NewPagerParam(1, 10)).Entity
+	entity.OrderBy = []dal.OrderField{dal.Desc("CreatedAt")}
+	stmts, err := mp.buildQuerySQL(context.Background(), entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch := "SELECT * FROM `op_log_%s` AS `op_log` WHERE (`Action` = ? AND `CreatedAt` >= ?)"
+	union := "(" + fmt.Sprintf(branch, "202609") + " UNION ALL " + fmt.Sprintf(branch, "202610") + ") AS `op_log`"
+	if expect := "SELECT * FROM " + union + " ORDER BY `CreatedAt` DESC LIMIT 10"; stmts[0].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+	if expect := "SELECT COUNT(*) 'Count' FROM " + union; stmts[1].text != expect {
+		t.Errorf("unexpected count sql: %s", stmts[1].text)
+	}
+	expectValues := []interface{}{"login", "2026-09-01", "login", "2026-09-01"}
+	if !reflect.DeepEqual(stmts[1].values, expectValues) {
+		t.Errorf("unexpected values: %v", stmts[1].values)
+	}
+
+	entity = dal.NewQueryEntity("op_log", dal.Where(dal.Eq("CreatedAt", "2020-01-01")).Condition)().Entity
+	stmts, err = mp.buildQuerySQL(context.Background(), entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "SELECT * FROM `op_log_202610` AS `op_log` WHERE (`CreatedAt` = ?)"; stmts[0].text != expect {
+		t.Errorf("unexpected sql: %s", stmts[0].text)
+	}
+}
+
+func TestTranTables(t *testing.T) {
+	mp := newTableRuleProvider(t, "op_log_202609", "op_log_202610")
+	ctx := context.Background()
+	stmts, err := mp.getTranStmts(ctx, dal.NewTranAEntity("op_log", map[string]interface{}{
+		"Action":    "login",
+		"CreatedAt": time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC),
+	}).Entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 || stmts[0].text != "INSERT INTO `op_log_202610`(`Action`,`CreatedAt`) VALUES(?,?)" {
+		t.Errorf("unexpected stmts: %v", stmts)
+	}
+
+	stmts, err = mp.getTranStmts(ctx, dal.NewTranBatchAEntity("op_log", []map[string]interface{}{
+		{"Action": "a", "CreatedAt": "2026-11-01 00:00:00"},
+		{"Action": "b", "CreatedAt": "2026-10-31 23:59:59"},
+	}).Entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 ||
+		stmts[0].text != "INSERT INTO `op_log_202610`(`Action`,`CreatedAt`) VALUES(?,?)" ||
+		stmts[1].text != "INSERT INTO `op_log_202611`(`Action`,`CreatedAt`) VALUES(?,?)" {
+		t.Errorf("unexpected stmts: %v", stmts)
+	}
+	if _, ok := mp.tableCache.get("op_log"); ok {
+		t.Error("expected cache to be cleared after writing a new table")
+	}
+
+	mp.tableCache.set("op_log", []string{"op_log_202609", "op_log_202610"})
+	stmts, err = mp.getTranStmts(ctx, dal.NewTranDEntity("op_log", dal.Where(dal.Lt("CreatedAt", "2026-10-01")).Condition).Entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 ||
+		stmts[0].text != "DELETE FROM `op_log_202609` WHERE (`CreatedAt` < ?)" ||
+		stmts[1].text != "DELETE FROM `op_log_202610` WHERE (`CreatedAt` < ?)" {
+		t.Errorf("unexpected stmts: %v", stmts)
+	}
+
+	if _, err = mp.getTranStmts(ctx, dal.NewTranAEntity("op_log", map[string]interface{}{"Action": "login"}).Entity); err == nil {
+		t.Error("expected error without time field")
+	}
+}
+
+func TestTableRuleLocation(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	rule, err := TableRule{Table: "op_log", Field: "CreatedAt"}.normalize(cst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 调用方的时区与规则的时区不同时，按照规则的时区计算物理表
+	for _, v := range []interface{}{
+		time.Date(2026, 10, 31, 20, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 31, 19, 0, 0, 0, time.FixedZone("EST", -5*3600)),
+		"2026-11-01 00:00:00",
+	} {
+		table, err := rule.route(map[string]interface{}{"CreatedAt": v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table != "op_log_202611" {
+			t.Errorf("%v: unexpected table %s", v, table)
+		}
+	}
+	if table, _ := rule.route(map[string]interface{}{"CreatedAt": time.Date(2026, 10, 31, 15, 59, 59, 0, time.UTC)}); table != "op_log_202610" {
+		t.Errorf("unexpected table %s", table)
+	}
+	if start, ok := rule.start("op_log_202611"); !ok || !start.Equal(time.Date(2026, 10, 31, 16, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start: %v", start)
+	}
+	existing := []string{"op_log_202610", "op_log_202611"}
+	cond := dal.Where(dal.Ge("CreatedAt", time.Date(2026, 10, 31, 20, 0, 0, 0, time.UTC))).Condition
+	if tables := rule.filter(existing, rule.fieldRange(cond)); !reflect.DeepEqual(tables, []string{"op_log_202611"}) {
+		t.Errorf("unexpected tables: %v", tables)
+	}
+	if _, err := (TableRule{Table: "op_log", Field: "CreatedAt", Location: "Nowhere/Unknown"}).normalize(nil); err == nil {
+		t.Error("expected error for unknown location")
+	}
+}
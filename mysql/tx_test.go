@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+func TestNestedTx(t *testing.T) {
+	db, r := openRecorder(t)
+	r.failOn = "UPDATE b"
+	mp := &MysqlProvider{db: db}
+	ctx := context.Background()
+	tx, err := mp.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := tx.ExecWithSQL("UPDATE a SET x=?", 1); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	// 嵌套事务失败时只回滚到保存点，外层事务继续执行
+	err = dal.RunTx(ctx, tx, func(inner dal.Provider) error {
+		return inner.ExecWithSQL("UPDATE b SET x=?", 2).Error
+	})
+	if err == nil {
+		t.Fatal("expected inner error")
+	}
+	err = dal.RunTx(ctx, tx, func(inner dal.Provider) error {
+		return inner.ExecWithSQL("UPDATE c SET x=?", 3).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"BEGIN",
+		"UPDATE a SET x=?",
+		"SAVEPOINT dal_sp_1",
+		"UPDATE b SET x=?",
+		"ROLLBACK TO SAVEPOINT dal_sp_1",
+		"SAVEPOINT dal_sp_2",
+		"UPDATE c SET x=?",
+		"RELEASE SAVEPOINT dal_sp_2",
+		"COMMIT",
+	}
+	if !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+	if err := tx.Rollback(); err != sql.ErrTxDone {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestNestedExecTrans(t *testing.T) {
+	db, r := openRecorder(t)
+	mp := &MysqlProvider{db: db}
+	tx, err := mp.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities := []dal.TranEntity{dal.NewTranSQLEntity("UPDATE a SET x=?", 1).Entity}
+	if result := tx.ExecTrans(entities); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"BEGIN", "SAVEPOINT dal_sp_1", "UPDATE a SET x=?", "RELEASE SAVEPOINT dal_sp_1", "ROLLBACK"}
+	if !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+}
+
+func TestNestedRunTxPanic(t *testing.T) {
+	db, r := openRecorder(t)
+	mp := &MysqlProvider{db: db}
+	tx, err := mp.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := runTxPanic(tx); v != "boom" {
+		t.Errorf("expected panic, got %v", v)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"BEGIN", "SAVEPOINT dal_sp_1", "UPDATE a SET x=?", "ROLLBACK TO SAVEPOINT dal_sp_1", "COMMIT"}
+	if !reflect.DeepEqual(r.statements(), expect) {
+		t.Errorf("unexpected statements: %v", r.statements())
+	}
+}
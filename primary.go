@@ -0,0 +1,17 @@
+package dal
+
+import "context"
+
+type primaryKey struct{}
+
+// WithPrimary 返回指定使用主库的上下文，配置了从库时，使用该上下文的查询操作将在主库中执行
+// 用于写入后需要立即读取最新数据的场景
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPrimary 上下文是否指定使用主库
+func IsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -12,6 +12,8 @@ const (
 	QList
 	// QPager 分页数据
 	QPager
+	// QCursor 游标分页数据
+	QCursor
 )
 
 // NewQueryEntity 创建新的查询实体
@@ -64,11 +66,103 @@ type PagerParam struct {
 	PageSize  int
 }
 
+// OrderField 排序字段
+type OrderField struct {
+	Field string
+	Desc  bool
+	// Expr 排序表达式，不为nil时代替Field原样写入SQL语句
+	Expr *SQLExpr
+}
+
+// Asc 升序排序字段
+func Asc(field string) OrderField {
+	return OrderField{Field: field}
+}
+
+// Desc 降序排序字段
+func Desc(field string) OrderField {
+	return OrderField{Field: field, Desc: true}
+}
+
+// OrderExpr 使用表达式排序
+// 例如：OrderExpr(Expr("FIELD(Status,?,?)", 2, 1), false)
+func OrderExpr(expr SQLExpr, desc bool) OrderField {
+	return OrderField{Desc: desc, Expr: &expr}
+}
+
+// JoinType 连接查询类型
+type JoinType byte
+
+const (
+	// JInner 内连接
+	JInner JoinType = iota + 1
+	// JLeft 左连接
+	JLeft
+	// JRight 右连接
+	JRight
+)
+
+// Join 连接查询
+// On 为连接条件(例如："s.ClassID=c.ID")，Values 为On中的格式化参数
+type Join struct {
+	Type   JoinType
+	Table  string
+	Alias  string
+	On     string
+	Values []interface{}
+}
+
+// InnerJoin 创建内连接
+func InnerJoin(table, alias, on string, values ...interface{}) Join {
+	return Join{Type: JInner, Table: table, Alias: alias, On: on, Values: values}
+}
+
+// LeftJoin 创建左连接
+func LeftJoin(table, alias, on string, values ...interface{}) Join {
+	return Join{Type: JLeft, Table: table, Alias: alias, On: on, Values: values}
+}
+
+// RightJoin 创建右连接
+func RightJoin(table, alias, on string, values ...interface{}) Join {
+	return Join{Type: JRight, Table: table, Alias: alias, On: on, Values: values}
+}
+
+// Qualify 使用表别名限定字段(例如：Qualify("s", "ID", "StuName") => ["s.ID", "s.StuName"])
+func Qualify(alias string, fields ...string) []string {
+	qualified := make([]string, len(fields))
+	for i, field := range fields {
+		qualified[i] = alias + "." + field
+	}
+	return qualified
+}
+
 // QueryEntity 提供数据查询结构体
+// 连接查询时，FieldsSelect中的字段可以使用别名限定(例如："s.StuName,c.ClassName")，
+// 查询结果以列名作为键，同名的列需要指定列别名
 type QueryEntity struct {
 	Table        string
 	FieldsSelect string
 	Condition    QueryCondition
 	ResultType   QueryResultType
 	PagerParam   PagerParam
+	// Alias 主表别名
+	Alias string
+	// Joins 连接查询
+	Joins []Join
+	// OrderBy 排序字段(COND_CV类型的条件中包含ORDER BY时不能使用)
+	OrderBy []OrderField
+	// GroupBy 分组字段
+	GroupBy []string
+	// Having 分组过滤条件（COND_CV类型需包含HAVING关键字）
+	Having QueryCondition
+	// Limit 列表查询的最大行数(0表示不限制，分页查询使用PagerParam)
+	// COND_CV类型的条件中包含LIMIT时不能使用Limit、Offset及分页查询
+	Limit int
+	// Offset 列表查询的起始行
+	Offset int
+	// CursorParam 游标分页参数
+	CursorParam CursorParam
+	// SelectExprs 查询表达式，原样追加到查询字段之后
+	// 例如：[]SQLExpr{Expr("COUNT(*) AS Num")}
+	SelectExprs []SQLExpr
 }
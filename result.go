@@ -1,11 +1,21 @@
 package dal
 
+import "fmt"
+
 // QueryPagerResult 分页查询结果类型
+// Rows 中的值保留数据库列对应的Go类型(int64,float64,time.Time,string,[]byte,NULL为nil)
 type QueryPagerResult struct {
 	Rows  []map[string]interface{} `json:"rows"`
 	Total int64                    `json:"total"`
 }
 
+// QueryCursorResult 游标分页查询结果类型
+type QueryCursorResult struct {
+	Rows       []map[string]interface{} `json:"rows"`
+	NextCursor string                   `json:"nextCursor"`
+	PrevCursor string                   `json:"prevCursor"`
+}
+
 // ResultError 提供统一的错误处理
 type ResultError struct {
 	Error error
@@ -33,4 +43,28 @@ type QueryConditionResult struct {
 type TranResult struct {
 	ResultError
 	Result int64
+	// Results 每个事务实体的执行结果，与执行的实体顺序一致
+	Results []ExecResult
+}
+
+// ExecResult 单个事务实体的执行结果
+// 批量新增拆分为多条语句时，RowsAffected为总影响行数，LastInsertId为第一条语句的新增ID
+type ExecResult struct {
+	RowsAffected int64 `json:"rowsAffected"`
+	LastInsertId int64 `json:"lastInsertId"`
+}
+
+// TranError 事务实体执行失败的错误信息
+type TranError struct {
+	// Index 执行失败的实体索引
+	Index int
+	Err   error
+}
+
+func (e *TranError) Error() string {
+	return fmt.Sprintf("entity %d: %v", e.Index, e.Err)
+}
+
+func (e *TranError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,55 @@
+package dal
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopIterate 在Iterate的回调中返回该错误可提前结束遍历（Iterate返回nil）
+var ErrStopIterate = errors.New("Stop iterate!")
+
+// Rows 提供逐行读取查询结果
+type Rows interface {
+	// Next 准备读取下一行，没有更多数据或发生错误时返回false
+	Next() bool
+	// Scan 将当前行解析到对应的指针地址
+	// (数据类型包括：map[string]string,map[string]interface{},struct)
+	Scan(output interface{}) error
+	// Err 获取遍历过程中发生的错误
+	Err() error
+	// Close 关闭结果集
+	Close() error
+}
+
+// Iterate 逐行遍历查询结果，fn返回错误时结束遍历并关闭结果集
+func Iterate(entity QueryEntity, fn func(rows Rows) error) error {
+	return IterateContext(context.Background(), entity, fn)
+}
+
+// IterateContext 逐行遍历查询结果（支持上下文）
+func IterateContext(ctx context.Context, entity QueryEntity, fn func(rows Rows) error) error {
+	rows, err := GDAL.QueryRowsContext(ctx, entity)
+	if err != nil {
+		return err
+	}
+	return EachRow(rows, fn)
+}
+
+// EachRow 逐行遍历结果集，遍历结束或fn返回错误时关闭结果集
+func EachRow(rows Rows, fn func(rows Rows) error) (err error) {
+	defer func() {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			if err == ErrStopIterate {
+				err = nil
+			}
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,48 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+)
+
+// countRows 返回n行数据并记录是否关闭
+type countRows struct {
+	n, index int
+	closed   bool
+}
+
+func (r *countRows) Next() bool {
+	r.index++
+	return r.index <= r.n
+}
+
+func (r *countRows) Scan(output interface{}) error { return nil }
+func (r *countRows) Err() error                    { return nil }
+
+func (r *countRows) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestEachRow(t *testing.T) {
+	rows := &countRows{n: 3}
+	var count int
+	err := EachRow(rows, func(rows Rows) error {
+		count++
+		return ErrStopIterate
+	})
+	if err != nil || count != 1 || !rows.closed {
+		t.Errorf("unexpected stop result: %v %d %v", err, count, rows.closed)
+	}
+
+	errCallback := errors.New("callback error")
+	rows = &countRows{n: 3}
+	if err := EachRow(rows, func(rows Rows) error { return errCallback }); err != errCallback || !rows.closed {
+		t.Errorf("unexpected callback result: %v %v", err, rows.closed)
+	}
+
+	rows, count = &countRows{n: 3}, 0
+	if err := EachRow(rows, func(rows Rows) error { count++; return nil }); err != nil || count != 3 || !rows.closed {
+		t.Errorf("unexpected result: %v %d %v", err, count, rows.closed)
+	}
+}
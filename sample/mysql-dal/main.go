@@ -102,8 +102,10 @@ func single() {
 
 func list() {
 	entity := dal.NewQueryEntity("student",
-		dal.NewCondition("order by Id limit 10").Condition,
+		dal.QueryCondition{},
 		"Id", "StuCode", "StuName")().Entity
+	entity.OrderBy = []dal.OrderField{dal.Asc("Id")}
+	entity.Limit = 10
 
 	var stuData []Student
 	err := dal.AssignList(entity, &stuData)
@@ -127,15 +129,15 @@ func transaction() {
 }
 
 func insertManyData() {
-	var entities []dal.TranEntity
+	var students []Student
 	for i := 0; i < 1000; i++ {
 		var stu Student
 		stu.StuCode = fmt.Sprintf("S-%d", i)
 		stu.StuName = fmt.Sprintf("SName-%d", i)
 		stu.Birthday = time.Now()
-		entities = append(entities, dal.NewTranAEntity("student", stu).Entity)
+		students = append(students, stu)
 	}
-	result := dal.ExecTrans(entities)
+	result := dal.Exec(dal.NewTranBatchAEntity("student", students).Entity)
 	if err := result.Error; err != nil {
 		panic(err)
 	}
@@ -144,9 +146,10 @@ func insertManyData() {
 
 func pager() {
 	entity := dal.NewQueryPagerEntity("student",
-		dal.NewCondition("where StuCode like ? order by ID", "S-%").Condition,
+		dal.Where(dal.Like("StuCode", "S-%")).Condition,
 		dal.NewPagerParam(1, 20),
 		"StuCode", "StuName", "Birthday").Entity
+	entity.OrderBy = []dal.OrderField{dal.Asc("ID")}
 	result, err := dal.Pager(entity)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,548 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antlinker/go-dal"
+	"github.com/antlinker/go-dal/utils"
+)
+
+func (p *Provider) Single(entity dal.QueryEntity) (map[string]string, error) {
+	return p.SingleContext(context.Background(), entity)
+}
+
+func (p *Provider) SingleContext(ctx context.Context, entity dal.QueryEntity) (map[string]string, error) {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return nil, err
+		}
+		return provider.SingleContext(ctx, entity)
+	}
+	rows, err := p.gather(ctx, indexes, entity, entity.Offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return make(map[string]string), nil
+	}
+	return stringify(rows[0]), nil
+}
+
+func (p *Provider) SingleWithSQL(sql string, values ...interface{}) (map[string]string, error) {
+	return p.SingleWithSQLContext(context.Background(), sql, values...)
+}
+
+func (p *Provider) SingleWithSQLContext(ctx context.Context, sql string, values ...interface{}) (map[string]string, error) {
+	provider, err := p.shard(0)
+	if err != nil {
+		return nil, err
+	}
+	return provider.SingleWithSQLContext(ctx, sql, values...)
+}
+
+func (p *Provider) AssignSingle(entity dal.QueryEntity, output interface{}) error {
+	return p.AssignSingleContext(context.Background(), entity, output)
+}
+
+func (p *Provider) AssignSingleContext(ctx context.Context, entity dal.QueryEntity, output interface{}) error {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return err
+		}
+		return provider.AssignSingleContext(ctx, entity, output)
+	}
+	rows, err := p.gather(ctx, indexes, entity, entity.Offset, 1)
+	if err != nil {
+		return err
+	}
+	data := make(map[string]interface{})
+	if len(rows) > 0 {
+		data = rows[0]
+	}
+	return utils.NewDecoder(&data).Decode(output)
+}
+
+func (p *Provider) AssignSingleWithSQL(sql string, values []interface{}, output interface{}) error {
+	return p.AssignSingleWithSQLContext(context.Background(), sql, values, output)
+}
+
+func (p *Provider) AssignSingleWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	provider, err := p.shard(0)
+	if err != nil {
+		return err
+	}
+	return provider.AssignSingleWithSQLContext(ctx, sql, values, output)
+}
+
+func (p *Provider) List(entity dal.QueryEntity) ([]map[string]string, error) {
+	return p.ListContext(context.Background(), entity)
+}
+
+func (p *Provider) ListContext(ctx context.Context, entity dal.QueryEntity) ([]map[string]string, error) {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return nil, err
+		}
+		return provider.ListContext(ctx, entity)
+	}
+	rows, err := p.gather(ctx, indexes, entity, entity.Offset, entity.Limit)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		data[i] = stringify(row)
+	}
+	return data, nil
+}
+
+func (p *Provider) ListWithSQL(sql string, values ...interface{}) ([]map[string]string, error) {
+	return p.ListWithSQLContext(context.Background(), sql, values...)
+}
+
+func (p *Provider) ListWithSQLContext(ctx context.Context, sql string, values ...interface{}) ([]map[string]string, error) {
+	provider, err := p.shard(0)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListWithSQLContext(ctx, sql, values...)
+}
+
+func (p *Provider) AssignList(entity dal.QueryEntity, output interface{}) error {
+	return p.AssignListContext(context.Background(), entity, output)
+}
+
+func (p *Provider) AssignListContext(ctx context.Context, entity dal.QueryEntity, output interface{}) error {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return err
+		}
+		return provider.AssignListContext(ctx, entity, output)
+	}
+	rows, err := p.gather(ctx, indexes, entity, entity.Offset, entity.Limit)
+	if err != nil {
+		return err
+	}
+	return utils.NewDecoder(&rows).Decode(output)
+}
+
+func (p *Provider) AssignListWithSQL(sql string, values []interface{}, output interface{}) error {
+	return p.AssignListWithSQLContext(context.Background(), sql, values, output)
+}
+
+func (p *Provider) AssignListWithSQLContext(ctx context.Context, sql string, values []interface{}, output interface{}) error {
+	provider, err := p.shard(0)
+	if err != nil {
+		return err
+	}
+	return provider.AssignListWithSQLContext(ctx, sql, values, output)
+}
+
+func (p *Provider) Pager(entity dal.QueryEntity) (dal.QueryPagerResult, error) {
+	return p.PagerContext(context.Background(), entity)
+}
+
+// PagerContext 查询分页数据，多个分片时各分片查询前N页数据后合并
+// (跨分片查询不合并分组，分组查询请在条件中指定分片键)
+// 每个分片都需要查询pageIndex*pageSize行，页码越大开销越大，深分页请使用CursorContext
+func (p *Provider) PagerContext(ctx context.Context, entity dal.QueryEntity) (qResult dal.QueryPagerResult, err error) {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return qResult, err
+		}
+		return provider.PagerContext(ctx, entity)
+	}
+	if err = checkOrderBy(entity.OrderBy); err != nil {
+		return
+	}
+	param := dal.NewPagerParam(entity.PagerParam.PageIndex, entity.PagerParam.PageSize)
+	shardEntity := entity
+	shardEntity.PagerParam = dal.NewPagerParam(1, param.PageIndex*param.PageSize)
+	results := make([]dal.QueryPagerResult, len(indexes))
+	err = p.each(indexes, func(i int, provider dal.Provider) (err error) {
+		results[i], err = provider.PagerContext(ctx, shardEntity)
+		return
+	})
+	if err != nil {
+		return
+	}
+	var rows []map[string]interface{}
+	for _, result := range results {
+		qResult.Total += result.Total
+		rows = append(rows, result.Rows...)
+	}
+	sortRows(rows, entity.OrderBy)
+	qResult.Rows = pageRows(rows, (param.PageIndex-1)*param.PageSize, param.PageSize)
+	return
+}
+
+func (p *Provider) Cursor(entity dal.QueryEntity) (dal.QueryCursorResult, error) {
+	return p.CursorContext(context.Background(), entity)
+}
+
+// CursorContext 查询游标分页数据，多个分片时各分片使用相同的游标查询后合并
+func (p *Provider) CursorContext(ctx context.Context, entity dal.QueryEntity) (qResult dal.QueryCursorResult, err error) {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return qResult, err
+		}
+		return provider.CursorContext(ctx, entity)
+	}
+	param := entity.CursorParam
+	var backward bool
+	if param.Cursor != "" {
+		if _, backward, err = dal.DecodeCursor(param.Cursor); err != nil {
+			return
+		}
+	}
+	results := make([]dal.QueryCursorResult, len(indexes))
+	err = p.each(indexes, func(i int, provider dal.Provider) (err error) {
+		results[i], err = provider.CursorContext(ctx, entity)
+		return
+	})
+	if err != nil {
+		return
+	}
+	var (
+		rows    []map[string]interface{}
+		hasMore bool
+	)
+	for _, result := range results {
+		rows = append(rows, result.Rows...)
+		if backward && result.PrevCursor != "" || !backward && result.NextCursor != "" {
+			hasMore = true
+		}
+	}
+	sortRows(rows, param.Keys)
+	size := param.PageSize()
+	if len(rows) > size {
+		hasMore = true
+		if backward {
+			rows = rows[len(rows)-size:]
+		} else {
+			rows = rows[:size]
+		}
+	}
+	return dal.NewCursorResult(rows, param, backward, hasMore)
+}
+
+func (p *Provider) Query(entity dal.QueryEntity) (interface{}, error) {
+	return p.QueryContext(context.Background(), entity)
+}
+
+func (p *Provider) QueryContext(ctx context.Context, entity dal.QueryEntity) (interface{}, error) {
+	switch entity.ResultType {
+	case dal.QSingle:
+		return p.SingleContext(ctx, entity)
+	case dal.QList:
+		return p.ListContext(ctx, entity)
+	case dal.QPager:
+		return p.PagerContext(ctx, entity)
+	case dal.QCursor:
+		return p.CursorContext(ctx, entity)
+	}
+	return nil, errors.New("The unknown `ResultType`")
+}
+
+func (p *Provider) QueryRows(entity dal.QueryEntity) (dal.Rows, error) {
+	return p.QueryRowsContext(context.Background(), entity)
+}
+
+// QueryRowsContext 查询数据并逐行读取，多个分片时合并后的结果保存在内存中
+func (p *Provider) QueryRowsContext(ctx context.Context, entity dal.QueryEntity) (dal.Rows, error) {
+	indexes, err := p.routeQuery(entity)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			return nil, err
+		}
+		return provider.QueryRowsContext(ctx, entity)
+	}
+	rows, err := p.gather(ctx, indexes, entity, entity.Offset, entity.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &sliceRows{rows: rows}, nil
+}
+
+func (p *Provider) QueryRowsWithSQL(sql string, values ...interface{}) (dal.Rows, error) {
+	return p.QueryRowsWithSQLContext(context.Background(), sql, values...)
+}
+
+func (p *Provider) QueryRowsWithSQLContext(ctx context.Context, sql string, values ...interface{}) (dal.Rows, error) {
+	provider, err := p.shard(0)
+	if err != nil {
+		return nil, err
+	}
+	return provider.QueryRowsWithSQLContext(ctx, sql, values...)
+}
+
+// each 在多个分片中并发执行fn，返回第一个错误
+func (p *Provider) each(indexes []int, fn func(i int, provider dal.Provider) error) error {
+	providers := make([]dal.Provider, len(indexes))
+	for i, index := range indexes {
+		provider, err := p.shard(index)
+		if err != nil {
+			return err
+		}
+		providers[i] = provider
+	}
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(providers))
+	)
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider dal.Provider) {
+			defer wg.Done()
+			errs[i] = fn(i, provider)
+		}(i, provider)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gather 从多个分片中查询数据，按照OrderBy排序后返回offset开始的limit行(limit为0时不限制)
+func (p *Provider) gather(ctx context.Context, indexes []int, entity dal.QueryEntity, offset, limit int) ([]map[string]interface{}, error) {
+	if err := checkOrderBy(entity.OrderBy); err != nil {
+		return nil, err
+	}
+	shardEntity := entity
+	shardEntity.ResultType = dal.QList
+	shardEntity.Offset = 0
+	shardEntity.Limit = 0
+	if limit > 0 {
+		shardEntity.Limit = offset + limit
+	}
+	results := make([][]map[string]interface{}, len(indexes))
+	err := p.each(indexes, func(i int, provider dal.Provider) error {
+		rows, err := provider.QueryRowsContext(ctx, shardEntity)
+		if err != nil {
+			return err
+		}
+		return dal.EachRow(rows, func(rows dal.Rows) error {
+			var row map[string]interface{}
+			if err := rows.Scan(&row); err != nil {
+				return err
+			}
+			results[i] = append(results[i], row)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := make([]map[string]interface{}, 0)
+	for _, rows := range results {
+		merged = append(merged, rows...)
+	}
+	sortRows(merged, entity.OrderBy)
+	if limit <= 0 {
+		limit = len(merged)
+	}
+	return pageRows(merged, offset, limit), nil
+}
+
+// pageRows 获取offset开始的limit行
+func pageRows(rows []map[string]interface{}, offset, limit int) []map[string]interface{} {
+	if offset >= len(rows) {
+		return make([]map[string]interface{}, 0)
+	}
+	end := offset + limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[offset:end]
+}
+
+// checkOrderBy 检查跨分片查询的排序字段，合并结果时只能按照查询结果中的列排序
+func checkOrderBy(orderBy []dal.OrderField) error {
+	for _, item := range orderBy {
+		if item.Expr != nil {
+			return ErrOrderExpr
+		}
+	}
+	return nil
+}
+
+// sortRows 按照排序字段对合并后的数据排序
+func sortRows(rows []map[string]interface{}, orderBy []dal.OrderField) {
+	if len(orderBy) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, item := range orderBy {
+			column := dal.ColumnName(item.Field)
+			c := compareValues(rowValue(rows[i], column), rowValue(rows[j], column))
+			if c == 0 {
+				continue
+			}
+			if item.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// rowValue 获取行数据中列的值(列名不区分大小写)
+func rowValue(row map[string]interface{}, column string) interface{} {
+	if value, ok := row[column]; ok {
+		return value
+	}
+	for k, v := range row {
+		if strings.EqualFold(k, column) {
+			return v
+		}
+	}
+	return nil
+}
+
+// compareValues 比较两个值，NULL最小，数值按照大小比较，时间按照先后比较，其它按照字符串比较
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := toFloat64(a); ok {
+		if y, ok := toFloat64(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(dal.FormatValue(a), dal.FormatValue(b))
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(v), 64)
+		return f, err == nil
+	}
+	if v, ok := toInt64(value); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func stringify(row map[string]interface{}) map[string]string {
+	data := make(map[string]string, len(row))
+	for k, v := range row {
+		data[k] = dal.FormatValue(v)
+	}
+	return data
+}
+
+// sliceRows 提供逐行读取内存中的数据
+type sliceRows struct {
+	rows  []map[string]interface{}
+	index int
+}
+
+func (r *sliceRows) Next() bool {
+	if r.index >= len(r.rows) {
+		return false
+	}
+	r.index++
+	return true
+}
+
+func (r *sliceRows) Scan(output interface{}) error {
+	if r.index == 0 || r.index > len(r.rows) {
+		return errors.New("Scan called without calling Next")
+	}
+	data := r.rows[r.index-1]
+	return utils.NewDecoder(&data).Decode(output)
+}
+
+func (r *sliceRows) Err() error {
+	return nil
+}
+
+func (r *sliceRows) Close() error {
+	r.index = len(r.rows)
+	return nil
+}
@@ -0,0 +1,252 @@
+// Package sharding 提供按分片键将数据分布到多个数据库的Provider
+//
+// 未配置分片规则的表、使用SQL语句的查询及执行在第一个分片中进行；
+// 查询条件中没有分片键时，查询所有分片并合并结果(按OrderBy排序)；
+// 事务中的操作只能在同一个分片中执行。
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/antlinker/go-dal"
+)
+
+var (
+	// ErrNoShardKey 缺少分片键
+	ErrNoShardKey = errors.New("Shard key is required!")
+	// ErrCrossShard 不支持跨分片的事务
+	ErrCrossShard = errors.New("Cross shard transaction is not supported!")
+	// ErrTxDone 事务已经提交或回滚
+	ErrTxDone = errors.New("Transaction has already been committed or rolled back!")
+	// ErrOrderExpr 跨分片查询不支持使用表达式排序(合并结果时无法计算表达式)
+	ErrOrderExpr = errors.New("Order expression is not supported in cross shard query!")
+)
+
+// Rule 表的分片规则
+type Rule struct {
+	// Table 表名
+	Table string
+	// Key 分片键(列名)
+	Key string
+	// Strategy 分片策略
+	Strategy Strategy
+}
+
+// Provider 分片Provider，实现dal.Provider接口
+type Provider struct {
+	shards []dal.Provider
+	rules  map[string]Rule
+	tx     *txState
+}
+
+// New 创建分片Provider
+// shards 各分片的Provider(例如使用dal.Use(name)获取的mysql实例)
+// 创建后可以使用dal.RegisterInstance注册为全局或命名实例
+func New(shards []dal.Provider, rules ...Rule) (*Provider, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("`shards` can't be empty")
+	}
+	p := &Provider{
+		shards: shards,
+		rules:  make(map[string]Rule),
+	}
+	for _, rule := range rules {
+		if rule.Table == "" || rule.Key == "" || rule.Strategy == nil {
+			return nil, errors.New("`Rule` requires `Table`, `Key` and `Strategy`")
+		}
+		if _, ok := p.rules[rule.Table]; ok {
+			return nil, fmt.Errorf("Rule of `%s` has been registered", rule.Table)
+		}
+		p.rules[rule.Table] = rule
+	}
+	return p, nil
+}
+
+// Shard 获取指定索引的分片
+func (p *Provider) Shard(index int) dal.Provider {
+	return p.shards[index]
+}
+
+// Intercept 为支持拦截器的分片注册拦截器
+func (p *Provider) Intercept(interceptors ...dal.Interceptor) {
+	for _, shard := range p.shards {
+		if v, ok := shard.(dal.Interceptable); ok {
+			v.Intercept(interceptors...)
+		}
+	}
+}
+
+// SetLogger 为支持日志的分片设置日志
+func (p *Provider) SetLogger(logger dal.Logger) {
+	for _, shard := range p.shards {
+		if v, ok := shard.(dal.Loggable); ok {
+			v.SetLogger(logger)
+		}
+	}
+}
+
+// shard 获取执行操作的分片，事务中返回该分片的事务
+func (p *Provider) shard(index int) (dal.Provider, error) {
+	if p.tx == nil {
+		return p.shards[index], nil
+	}
+	return p.tx.bind(index)
+}
+
+func (p *Provider) all() []int {
+	indexes := make([]int, len(p.shards))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// routeValues 根据分片键的值获取分片索引(去重并排序)
+func (p *Provider) routeValues(rule Rule, values []interface{}) ([]int, error) {
+	set := make(map[int]bool)
+	for _, value := range values {
+		index, err := rule.Strategy.Shard(value, len(p.shards))
+		if err != nil {
+			return nil, err
+		}
+		set[index] = true
+	}
+	indexes := make([]int, 0, len(set))
+	for index := range set {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// routeQuery 获取查询实体对应的分片，条件中没有分片键时返回所有分片
+func (p *Provider) routeQuery(entity dal.QueryEntity) ([]int, error) {
+	rule, ok := p.rules[entity.Table]
+	if !ok {
+		return []int{0}, nil
+	}
+	values, ok := keyValues(entity.Condition, rule.Key)
+	if !ok {
+		return p.all(), nil
+	}
+	return p.routeValues(rule, values)
+}
+
+// routeTran 获取事务实体对应的分片及各分片执行的实体(批量新增按照分片拆分数据)
+// 新增操作必须包含分片键，更新及删除操作的条件中没有分片键时返回所有分片
+func (p *Provider) routeTran(entity dal.TranEntity) (indexes []int, entities []dal.TranEntity, err error) {
+	rule, ok := p.rules[entity.Table]
+	if !ok || entity.Operate == dal.TSQL {
+		return []int{0}, []dal.TranEntity{entity}, nil
+	}
+	switch entity.Operate {
+	case dal.TA, dal.TAU, dal.TAI, dal.TR:
+		value, ok := fieldValue(entity.FieldsValue, rule.Key)
+		if !ok {
+			return nil, nil, ErrNoShardKey
+		}
+		index, err := rule.Strategy.Shard(value, len(p.shards))
+		if err != nil {
+			return nil, nil, err
+		}
+		return []int{index}, []dal.TranEntity{entity}, nil
+	case dal.TBA:
+		batches := make(map[int][]map[string]interface{})
+		for _, row := range entity.BatchValues {
+			value, ok := fieldValue(row, rule.Key)
+			if !ok {
+				return nil, nil, ErrNoShardKey
+			}
+			index, err := rule.Strategy.Shard(value, len(p.shards))
+			if err != nil {
+				return nil, nil, err
+			}
+			batches[index] = append(batches[index], row)
+		}
+		for index := range batches {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		for _, index := range indexes {
+			item := entity
+			item.BatchValues = batches[index]
+			entities = append(entities, item)
+		}
+		return
+	}
+	values, ok := keyValues(entity.Condition, rule.Key)
+	if !ok {
+		indexes = p.all()
+	} else if indexes, err = p.routeValues(rule, values); err != nil {
+		return
+	}
+	for range indexes {
+		entities = append(entities, entity)
+	}
+	return
+}
+
+// keyValues 从条件中获取分片键的值
+// 支持键值条件以及条件表达式中的Eq、In(使用Or连接时每个分支都需要包含分片键)
+func keyValues(cond dal.QueryCondition, key string) ([]interface{}, bool) {
+	switch cond.CType {
+	case dal.COND_KV:
+		if value, ok := fieldValue(cond.FieldsKv, key); ok {
+			return []interface{}{value}, true
+		}
+	case dal.COND_EXPR:
+		return exprValues(cond.Expr, key)
+	}
+	return nil, false
+}
+
+func exprValues(expr dal.CondExpr, key string) ([]interface{}, bool) {
+	switch expr.Op {
+	case dal.OpEq:
+		if matchKey(expr.Field, key) && len(expr.Values) == 1 && expr.Values[0] != nil {
+			return expr.Values, true
+		}
+	case dal.OpIn:
+		if matchKey(expr.Field, key) {
+			return dal.ExpandValues(expr.Values), true
+		}
+	case dal.OpAnd:
+		for _, item := range expr.Exprs {
+			if values, ok := exprValues(item, key); ok {
+				return values, true
+			}
+		}
+	case dal.OpOr:
+		if len(expr.Exprs) == 0 {
+			return nil, false
+		}
+		var values []interface{}
+		for _, item := range expr.Exprs {
+			itemValues, ok := exprValues(item, key)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, itemValues...)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// fieldValue 获取分片键的值，值为nil时视为不存在
+func fieldValue(fields map[string]interface{}, key string) (interface{}, bool) {
+	for k, v := range fields {
+		if matchKey(k, key) && v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// matchKey 判断字段是否为分片键(忽略表别名、反引号及大小写)
+func matchKey(field, key string) bool {
+	return strings.EqualFold(dal.ColumnName(field), key)
+}
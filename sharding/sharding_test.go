@@ -0,0 +1,156 @@
+package sharding
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/antlinker/go-dal"
+)
+
+// fakeShard 记录执行的实体并返回固定数据的分片
+type fakeShard struct {
+	dal.Provider
+	rows     []map[string]interface{}
+	entities []dal.TranEntity
+}
+
+func (s *fakeShard) QueryRowsContext(ctx context.Context, entity dal.QueryEntity) (dal.Rows, error) {
+	rows := s.rows
+	if entity.Limit > 0 && len(rows) > entity.Limit {
+		rows = rows[:entity.Limit]
+	}
+	return &sliceRows{rows: rows}, nil
+}
+
+func (s *fakeShard) ExecContext(ctx context.Context, entity dal.TranEntity) dal.TranResult {
+	s.entities = append(s.entities, entity)
+	return dal.TranResult{Result: 1, Results: []dal.ExecResult{{RowsAffected: 1}}}
+}
+
+func (s *fakeShard) ExecTransContext(ctx context.Context, entities []dal.TranEntity) dal.TranResult {
+	s.entities = append(s.entities, entities...)
+	return dal.TranResult{Result: int64(len(entities))}
+}
+
+func newTestProvider(t *testing.T) (*Provider, []*fakeShard) {
+	shards := []*fakeShard{
+		{rows: []map[string]interface{}{{"ID": int64(2), "Name": "b"}, {"ID": int64(4), "Name": "d"}}},
+		{rows: []map[string]interface{}{{"ID": int64(1), "Name": "a"}, {"ID": int64(3), "Name": "c"}}},
+	}
+	p, err := New([]dal.Provider{shards[0], shards[1]}, Rule{Table: "student", Key: "ID", Strategy: HashMod{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, shards
+}
+
+func TestStrategy(t *testing.T) {
+	if index, _ := (HashMod{}).Shard("13", 4); index != 1 {
+		t.Errorf("unexpected hash mod index: %d", index)
+	}
+	if index, _ := (HashMod{}).Shard(int64(13), 4); index != 1 {
+		t.Errorf("unexpected hash mod index: %d", index)
+	}
+	r := Range{Bounds: []int64{100, 200}}
+	for value, expect := range map[int]int{0: 0, 100: 1, 199: 1, 500: 2} {
+		if index, err := r.Shard(value, 3); err != nil || index != expect {
+			t.Errorf("%d: unexpected range index: %d %v", value, index, err)
+		}
+	}
+	if _, err := r.Shard(500, 2); err == nil {
+		t.Error("expected out of range error")
+	}
+	l := Lookup{"bj": 0, "sh": 1}
+	if index, err := l.Shard([]byte("sh"), 2); err != nil || index != 1 {
+		t.Errorf("unexpected lookup index: %d %v", index, err)
+	}
+	if _, err := l.Shard("gz", 2); err == nil {
+		t.Error("expected not found error")
+	}
+}
+
+func TestRouteQuery(t *testing.T) {
+	p, _ := newTestProvider(t)
+	tests := []struct {
+		cond   dal.QueryCondition
+		expect []int
+	}{
+		{dal.Where(dal.Eq("ID", 3), dal.Gt("Age", 18)).Condition, []int{1}},
+		{dal.Where(dal.In("s.ID", []int{1, 3})).Condition, []int{1}},
+		{dal.Where(dal.Or(dal.Eq("ID", 1), dal.Eq("ID", 2))).Condition, []int{0, 1}},
+		{dal.Where(dal.Or(dal.Eq("ID", 1), dal.Eq("Age", 2))).Condition, []int{0, 1}},
+		{dal.NewFieldsKvCondition(map[string]interface{}{"ID": 4}).Condition, []int{0}},
+		{dal.QueryCondition{}, []int{0, 1}},
+	}
+	for i, test := range tests {
+		indexes, err := p.routeQuery(dal.NewQueryEntity("student", test.cond)().Entity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(indexes, test.expect) {
+			t.Errorf("%d: unexpected shards: %v", i, indexes)
+		}
+	}
+	indexes, _ := p.routeQuery(dal.NewQueryEntity("class", dal.QueryCondition{})().Entity)
+	if !reflect.DeepEqual(indexes, []int{0}) {
+		t.Errorf("unexpected shards for unsharded table: %v", indexes)
+	}
+}
+
+func TestScatterList(t *testing.T) {
+	p, _ := newTestProvider(t)
+	entity := dal.NewQueryEntity("student", dal.QueryCondition{}, "ID", "Name")().Entity
+	entity.OrderBy = []dal.OrderField{dal.Desc("ID")}
+	entity.Offset = 1
+	entity.Limit = 2
+	data, err := p.List(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []map[string]string{{"ID": "3", "Name": "c"}, {"ID": "2", "Name": "b"}}
+	if !reflect.DeepEqual(data, expect) {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
+
+func TestScatterOrderExpr(t *testing.T) {
+	p, _ := newTestProvider(t)
+	entity := dal.NewQueryEntity("student", dal.QueryCondition{}, "ID", "Name")().Entity
+	entity.OrderBy = []dal.OrderField{dal.OrderExpr(dal.Expr("FIELD(Name,?,?)", "c", "a"), false)}
+	if _, err := p.List(entity); err != ErrOrderExpr {
+		t.Errorf("unexpected list error: %v", err)
+	}
+	pager := dal.NewQueryPagerEntity("student", dal.QueryCondition{}, dal.NewPagerParam(1, 2), "ID", "Name").Entity
+	pager.OrderBy = entity.OrderBy
+	if _, err := p.Pager(pager); err != ErrOrderExpr {
+		t.Errorf("unexpected pager error: %v", err)
+	}
+	// 条件中包含分片键时在单个分片中查询，可以使用表达式排序
+	entity.Condition = dal.Where(dal.Eq("ID", 1)).Condition
+	if _, err := p.QueryRows(entity); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecRoute(t *testing.T) {
+	p, shards := newTestProvider(t)
+	rows := []map[string]interface{}{{"ID": 1}, {"ID": 2}, {"ID": 3}}
+	result := p.Exec(dal.NewTranBatchAEntity("student", rows).Entity)
+	if result.Error != nil || result.Result != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(shards[0].entities[0].BatchValues) != 1 || len(shards[1].entities[0].BatchValues) != 2 {
+		t.Error("unexpected batch split")
+	}
+	if result := p.Exec(dal.NewTranAEntity("student", map[string]interface{}{"Name": "x"}).Entity); result.Error != ErrNoShardKey {
+		t.Errorf("expected ErrNoShardKey, got %v", result.Error)
+	}
+	result = p.ExecTrans([]dal.TranEntity{
+		dal.NewTranAEntity("student", map[string]interface{}{"ID": 1}).Entity,
+		dal.NewTranAEntity("student", map[string]interface{}{"ID": 2}).Entity,
+	})
+	if terr, ok := result.Error.(*dal.TranError); !ok || terr.Err != ErrCrossShard || terr.Index != 1 {
+		t.Errorf("expected cross shard error, got %v", result.Error)
+	}
+}
@@ -0,0 +1,102 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Strategy 分片策略，根据分片键的值选择分片
+type Strategy interface {
+	// Shard 返回分片索引(0 <= index < shards)
+	Shard(value interface{}, shards int) (int, error)
+}
+
+// HashMod 取模分片，整数(包括整数字符串)直接取模，其它值使用crc32取模
+type HashMod struct{}
+
+func (HashMod) Shard(value interface{}, shards int) (int, error) {
+	if value == nil {
+		return 0, ErrNoShardKey
+	}
+	if v, ok := toInt64(value); ok {
+		index := v % int64(shards)
+		if index < 0 {
+			index += int64(shards)
+		}
+		return int(index), nil
+	}
+	return int(crc32.ChecksumIEEE([]byte(fmt.Sprint(value))) % uint32(shards)), nil
+}
+
+// Range 范围分片，Bounds为各分片的上界(不包含)且递增
+// 例如：Range{Bounds: []int64{1000000, 2000000}}，小于1000000为0号分片，小于2000000为1号分片，其它为2号分片
+type Range struct {
+	Bounds []int64
+}
+
+func (r Range) Shard(value interface{}, shards int) (int, error) {
+	v, ok := toInt64(value)
+	if !ok {
+		return 0, fmt.Errorf("Range shard key must be an integer, got %v", value)
+	}
+	index := len(r.Bounds)
+	for i, bound := range r.Bounds {
+		if v < bound {
+			index = i
+			break
+		}
+	}
+	if index >= shards {
+		return 0, fmt.Errorf("Shard key %d is out of range", v)
+	}
+	return index, nil
+}
+
+// Lookup 查找表分片，以分片键值的字符串形式查找分片索引
+type Lookup map[string]int
+
+func (l Lookup) Shard(value interface{}, shards int) (int, error) {
+	if value == nil {
+		return 0, ErrNoShardKey
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	index, ok := l[fmt.Sprint(value)]
+	if !ok || index < 0 || index >= shards {
+		return 0, fmt.Errorf("Shard key %v is not found", value)
+	}
+	return index, nil
+}
+
+// toInt64 将整数、整数值的浮点数及整数字符串转换为int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		return n, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f > math.MaxInt64 || f < math.MinInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
@@ -0,0 +1,195 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/antlinker/go-dal"
+)
+
+func (p *Provider) Exec(entity dal.TranEntity) dal.TranResult {
+	return p.ExecContext(context.Background(), entity)
+}
+
+// ExecContext 执行单条事务性操作
+// 更新、删除或批量新增涉及多个分片时，在各分片中分别执行(各分片之间不保证原子性)，Result为总影响行数
+func (p *Provider) ExecContext(ctx context.Context, entity dal.TranEntity) (result dal.TranResult) {
+	indexes, entities, err := p.routeTran(entity)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	if len(indexes) == 1 {
+		provider, err := p.shard(indexes[0])
+		if err != nil {
+			result.Error = err
+			return
+		}
+		return provider.ExecContext(ctx, entities[0])
+	}
+	var execResult dal.ExecResult
+	for i, index := range indexes {
+		provider, err := p.shard(index)
+		if err != nil {
+			result.Error = err
+			return
+		}
+		shardResult := provider.ExecContext(ctx, entities[i])
+		if err := shardResult.Error; err != nil {
+			result.Error = err
+			return
+		}
+		for _, item := range shardResult.Results {
+			execResult.RowsAffected += item.RowsAffected
+			if execResult.LastInsertId == 0 {
+				execResult.LastInsertId = item.LastInsertId
+			}
+		}
+	}
+	result.Result = execResult.RowsAffected
+	result.Results = []dal.ExecResult{execResult}
+	return
+}
+
+func (p *Provider) ExecTrans(entities []dal.TranEntity) dal.TranResult {
+	return p.ExecTransContext(context.Background(), entities)
+}
+
+// ExecTransContext 执行多条事务性操作，所有实体必须属于同一个分片
+func (p *Provider) ExecTransContext(ctx context.Context, entities []dal.TranEntity) (result dal.TranResult) {
+	if len(entities) == 0 {
+		result.Error = errors.New("`entities` can't be empty")
+		return
+	}
+	target := -1
+	routed := make([]dal.TranEntity, len(entities))
+	for i, entity := range entities {
+		indexes, items, err := p.routeTran(entity)
+		if err != nil {
+			result.Error = &dal.TranError{Index: i, Err: err}
+			return
+		}
+		for _, index := range indexes {
+			if target == -1 {
+				target = index
+			} else if target != index {
+				result.Error = &dal.TranError{Index: i, Err: ErrCrossShard}
+				return
+			}
+		}
+		routed[i] = entity
+		if len(items) == 1 {
+			routed[i] = items[0]
+		}
+	}
+	if target == -1 {
+		target = 0
+	}
+	provider, err := p.shard(target)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	return provider.ExecTransContext(ctx, routed)
+}
+
+func (p *Provider) ExecWithSQL(sql string, values ...interface{}) dal.TranResult {
+	return p.ExecWithSQLContext(context.Background(), sql, values...)
+}
+
+// ExecWithSQLContext 在第一个分片中执行sql语句，其它分片使用Shard(index)执行
+func (p *Provider) ExecWithSQLContext(ctx context.Context, sql string, values ...interface{}) (result dal.TranResult) {
+	provider, err := p.shard(0)
+	if err != nil {
+		result.Error = err
+		return
+	}
+	return provider.ExecWithSQLContext(ctx, sql, values...)
+}
+
+func (p *Provider) Begin() (dal.Tx, error) {
+	return p.BeginContext(context.Background())
+}
+
+// BeginContext 开启事务，事务在第一次操作时绑定到对应的分片，之后只能操作该分片
+func (p *Provider) BeginContext(ctx context.Context) (dal.Tx, error) {
+	state := &txState{
+		ctx:    ctx,
+		parent: p.tx,
+		shards: p.shards,
+		index:  -1,
+	}
+	provider := *p
+	provider.tx = state
+	return &shardTx{Provider: &provider, state: state}, nil
+}
+
+// txState 分片事务的状态
+type txState struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	parent *txState
+	shards []dal.Provider
+	index  int
+	tx     dal.Tx
+	done   bool
+}
+
+// bind 获取分片的事务，第一次调用时在该分片中开启事务(嵌套事务先绑定外层事务)
+func (s *txState) bind(index int) (dal.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return nil, ErrTxDone
+	}
+	if s.tx != nil {
+		if s.index != index {
+			return nil, ErrCrossShard
+		}
+		return s.tx, nil
+	}
+	var base dal.Provider = s.shards[index]
+	if s.parent != nil {
+		var err error
+		if base, err = s.parent.bind(index); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := base.BeginContext(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.tx, s.index = tx, index
+	return tx, nil
+}
+
+func (s *txState) finish(commit bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return ErrTxDone
+	}
+	s.done = true
+	if s.tx == nil {
+		return nil
+	}
+	if commit {
+		return s.tx.Commit()
+	}
+	return s.tx.Rollback()
+}
+
+// shardTx 分片事务
+type shardTx struct {
+	*Provider
+	state *txState
+}
+
+func (t *shardTx) Commit() error {
+	return t.state.finish(true)
+}
+
+func (t *shardTx) Rollback() error {
+	return t.state.finish(false)
+}
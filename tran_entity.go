@@ -12,19 +12,29 @@ const (
 	TU
 	// TD 删除
 	TD
+	// TBA 批量新增
+	TBA
+	// TAU 新增，主键或唯一键冲突时更新(INSERT ... ON DUPLICATE KEY UPDATE)
+	TAU
+	// TAI 新增，主键或唯一键冲突时忽略(INSERT IGNORE)
+	TAI
+	// TR 替换(REPLACE INTO)
+	TR
+	// TSQL 执行sql语句
+	TSQL
 )
 
 // NewTranAEntity 创建新增实体
 // fieldsValue 数据类型(map[string]interface{} or map[string]string or struct)
-// 如果fieldsValue为struct类型，只保留非零值字段
-func NewTranAEntity(table string, fieldsValue interface{}) TranEntityResult {
+// 如果fieldsValue为struct类型，只保留非零值字段(可使用IncludeZero保留零值字段)
+// nil指针字段与无效的sql.Null*字段写入NULL
+func NewTranAEntity(table string, fieldsValue interface{}, opts ...EntityOption) TranEntityResult {
 	var result TranEntityResult
 	entity := TranEntity{
 		Table:   table,
 		Operate: TA,
 	}
-	var fields map[string]interface{}
-	err := utils.NewDecoder(fieldsValue).Decode(&fields)
+	fields, err := decodeFields(fieldsValue, opts, utils.KeepNull())
 	if err != nil {
 		result.Error = err
 	}
@@ -35,16 +45,16 @@ func NewTranAEntity(table string, fieldsValue interface{}) TranEntityResult {
 
 // NewTranUEntity 创建更新实体
 // fieldsValue 数据类型(map[string]interface{} or map[string]string or struct)
-// 如果fieldsValue为struct类型，只保留非零值字段
-func NewTranUEntity(table string, fieldsValue interface{}, cond QueryCondition) TranEntityResult {
+// 如果fieldsValue为struct类型，只保留非零值字段(可使用IncludeZero保留零值字段)
+// nil指针字段与无效的sql.Null*字段更新为NULL
+func NewTranUEntity(table string, fieldsValue interface{}, cond QueryCondition, opts ...EntityOption) TranEntityResult {
 	var result TranEntityResult
 	entity := TranEntity{
 		Table:     table,
 		Operate:   TU,
 		Condition: cond,
 	}
-	var fields map[string]interface{}
-	err := utils.NewDecoder(fieldsValue).Decode(&fields)
+	fields, err := decodeFields(fieldsValue, opts, utils.KeepNull())
 	if err != nil {
 		result.Error = err
 		return result
@@ -65,10 +75,116 @@ func NewTranDEntity(table string, cond QueryCondition) TranEntityResult {
 	return result
 }
 
+// NewTranBatchAEntity 创建批量新增实体
+// rows 数据类型([]map[string]interface{} or []map[string]string or []struct)
+// 各行缺少的列使用默认值(DEFAULT)，执行时按照语句大小拆分为多条INSERT语句
+func NewTranBatchAEntity(table string, rows interface{}, opts ...EntityOption) TranEntityResult {
+	var result TranEntityResult
+	entity := TranEntity{
+		Table:   table,
+		Operate: TBA,
+	}
+	var batchValues []map[string]interface{}
+	err := newDecoder(rows, opts, utils.KeepNull()).Decode(&batchValues)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	entity.BatchValues = batchValues
+	result.Entity = entity
+	return result
+}
+
+// NewTranUpsertEntity 创建新增或更新实体(INSERT ... ON DUPLICATE KEY UPDATE)
+// 执行结果为MySQL的影响行数：新增的行为1，更新的行为2，值未改变的行为0
+// fieldsValue 数据类型(map[string]interface{} or map[string]string or struct)
+// updateFields 冲突时使用新增值更新的列，为空时更新所有新增列
+func NewTranUpsertEntity(table string, fieldsValue interface{}, updateFields []string, opts ...EntityOption) TranEntityResult {
+	result := newTranAEntity(table, TAU, fieldsValue, opts)
+	result.Entity.UpdateFields = updateFields
+	return result
+}
+
+// NewTranUpsertValuesEntity 创建新增或更新实体(INSERT ... ON DUPLICATE KEY UPDATE)
+// updateValues 冲突时更新的列及值，值可以使用Expr指定SQL表达式
+// 例如：NewTranUpsertValuesEntity("stat", stat, map[string]interface{}{"Count": Expr("Count+?", 1)})
+func NewTranUpsertValuesEntity(table string, fieldsValue interface{}, updateValues map[string]interface{}, opts ...EntityOption) TranEntityResult {
+	result := newTranAEntity(table, TAU, fieldsValue, opts)
+	result.Entity.UpdateValues = updateValues
+	return result
+}
+
+// NewTranIgnoreEntity 创建新增实体，主键或唯一键冲突时忽略(INSERT IGNORE)
+func NewTranIgnoreEntity(table string, fieldsValue interface{}, opts ...EntityOption) TranEntityResult {
+	return newTranAEntity(table, TAI, fieldsValue, opts)
+}
+
+// NewTranReplaceEntity 创建替换实体(REPLACE INTO)
+func NewTranReplaceEntity(table string, fieldsValue interface{}, opts ...EntityOption) TranEntityResult {
+	return newTranAEntity(table, TR, fieldsValue, opts)
+}
+
+func newTranAEntity(table string, operate TranOperate, fieldsValue interface{}, opts []EntityOption) TranEntityResult {
+	var result TranEntityResult
+	entity := TranEntity{
+		Table:   table,
+		Operate: operate,
+	}
+	fields, err := decodeFields(fieldsValue, opts, utils.KeepNull())
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	entity.FieldsValue = fields
+	result.Entity = entity
+	return result
+}
+
+// NewTranSQLEntity 创建sql语句实体，可以与其它事务实体一起执行
+func NewTranSQLEntity(sql string, values ...interface{}) TranEntityResult {
+	var result TranEntityResult
+	result.Entity = TranEntity{
+		Operate: TSQL,
+		SQL:     sql,
+		Values:  values,
+	}
+	return result
+}
+
+// InsertIDRef 引用同一批事务中前面实体的新增ID
+type InsertIDRef struct {
+	index int
+	valid bool
+}
+
+// InsertID 创建新增ID引用，index为ExecTrans中前面实体的索引
+// 例如：NewTranAEntity("class_student", map[string]interface{}{"ClassID": InsertID(0), "StuCode": "S001"})
+func InsertID(index int) InsertIDRef {
+	return InsertIDRef{index: index, valid: true}
+}
+
+// Index 引用的实体索引
+func (r InsertIDRef) Index() int {
+	if !r.valid {
+		return -1
+	}
+	return r.index
+}
+
 // TranEntity 提供事务性操作结构体
 type TranEntity struct {
 	Table       string
 	Operate     TranOperate
 	FieldsValue map[string]interface{}
 	Condition   QueryCondition
+	// BatchValues 批量新增的数据
+	BatchValues []map[string]interface{}
+	// UpdateFields 新增冲突时使用新增值更新的列(TAU)
+	UpdateFields []string
+	// UpdateValues 新增冲突时更新的列及值(TAU)，优先于UpdateFields
+	UpdateValues map[string]interface{}
+	// SQL sql语句(TSQL)
+	SQL string
+	// Values sql语句的参数(TSQL)
+	Values []interface{}
 }
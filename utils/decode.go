@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -8,8 +10,38 @@ import (
 	"time"
 )
 
+// timeFormat 时间转换为字符串时使用的格式，与dal.TimeFormat一致(dal引用utils，不能反向引用)
+const timeFormat = "2006-01-02 15:04:05.999999"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
 var TimeFormats = []string{"1/2/2006", "1/2/2006 15:4:5", "2006-1-2 15:4:5", "2006-1-2 15:4", "2006-1-2", "1-2", "15:4:5", "15:4", "15", "15:4:5 Jan 2, 2006 MST"}
 
+// TextValue 数据库返回的值及列的原始文本
+// 解析到字符串(及非时间类型的sql.Scanner)时使用原始文本，避免重新格式化(例如DATE列保持2016-10-13)，
+// 解析到其他类型时使用Value
+type TextValue struct {
+	Value interface{}
+	Text  string
+}
+
+// unwrap 根据解析的目标类型获取原始文本或值，目标为指针时在解析指针元素时处理
+func (v TextValue) unwrap(outputValue reflect.Value) interface{} {
+	switch {
+	case outputValue.Kind() == reflect.Ptr:
+		return v
+	case outputValue.Kind() == reflect.String:
+		return v.Text
+	case outputValue.CanAddr() && outputValue.Addr().Type().Implements(scannerType) && !isTimeScanner(outputValue.Type()):
+		return v.Text
+	}
+	return v.Value
+}
+
 // Decoder is the interface that wraps the basic Read method.
 type Decoder interface {
 	// Decode data type conversion
@@ -17,12 +49,58 @@ type Decoder interface {
 }
 
 // NewDecoder Get Decoder interface
-func NewDecoder(val interface{}) Decoder {
-	return &decoder{input: val}
+func NewDecoder(val interface{}, opts ...DecodeOption) Decoder {
+	d := &decoder{input: val}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// DecodeOption 解析选项
+type DecodeOption func(*decoder)
+
+// KeepNull 结构体转换为map时，保留值为nil的指针字段及无效的sql.Null*字段（值为nil，对应SQL NULL）
+// 默认情况下这些字段与零值字段一样被忽略
+func KeepNull() DecodeOption {
+	return func(d *decoder) {
+		d.keepNull = true
+	}
+}
+
+// IncludeZero 结构体转换为map时保留零值字段
+// 未指定fields时保留所有字段，否则只保留指定字段(列名或字段名称，不区分大小写)
+// 标记为omitempty的字段仍然被忽略
+func IncludeZero(fields ...string) DecodeOption {
+	return func(d *decoder) {
+		if len(fields) == 0 {
+			d.includeZero = true
+			return
+		}
+		if d.zeroFields == nil {
+			d.zeroFields = make(map[string]bool)
+		}
+		for _, field := range fields {
+			d.zeroFields[strings.ToLower(field)] = true
+		}
+	}
 }
 
 type decoder struct {
-	input interface{}
+	input       interface{}
+	keepNull    bool
+	includeZero bool
+	zeroFields  map[string]bool
+}
+
+// keepZero 检查字段为零值(或NULL)时是否保留
+func (d *decoder) keepZero(field reflect.StructField, tag fieldTag) bool {
+	if tag.omitEmpty {
+		return false
+	}
+	return d.includeZero ||
+		d.zeroFields[strings.ToLower(tag.name)] ||
+		d.zeroFields[strings.ToLower(field.Name)]
 }
 
 func (d *decoder) error(errInfo string) error {
@@ -53,12 +131,35 @@ func (d *decoder) getKind(val reflect.Value) reflect.Kind {
 }
 
 func (d *decoder) decode(data interface{}, outputValue reflect.Value) (err error) {
+	if v, ok := data.(TextValue); ok {
+		data = v.unwrap(outputValue)
+	}
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			data = nil
+		} else {
+			data = v.Elem().Interface()
+		}
+	}
+	if outputValue.CanAddr() && outputValue.Addr().Type().Implements(scannerType) {
+		return d.decodeScanner(data, outputValue)
+	}
+	if v, ok := data.(driver.Valuer); ok {
+		if data, err = v.Value(); err != nil {
+			return
+		}
+	}
 	dataVal := reflect.Indirect(reflect.ValueOf(data))
 	if !dataVal.IsValid() {
 		outputValue.Set(reflect.Zero(outputValue.Type()))
 		return
 	}
-	switch outputKind := d.getKind(outputValue); outputKind {
+	outputKind := d.getKind(outputValue)
+	// 数据库返回的[]byte按字符串处理
+	if v, ok := data.([]byte); ok && outputKind != reflect.Slice && outputKind != reflect.Interface {
+		data = string(v)
+	}
+	switch outputKind {
 	case reflect.Bool:
 		err = d.decodeBool(data, outputValue)
 	case reflect.String:
@@ -82,6 +183,8 @@ func (d *decoder) decode(data interface{}, outputValue reflect.Value) (err error
 		err = d.decodeSlice(data, outputValue)
 	case reflect.Interface:
 		err = d.decodeBasic(data, outputValue)
+	case reflect.Ptr:
+		err = d.decodePtr(data, outputValue)
 	default:
 		err = fmt.Errorf("Unsupported type: %s", outputKind)
 	}
@@ -106,6 +209,8 @@ func (d *decoder) decodeString(data interface{}, val reflect.Value) error {
 		val.SetString(strconv.FormatUint(dataVal.Uint(), 10))
 	case dataKind == reflect.Float32:
 		val.SetString(strconv.FormatFloat(dataVal.Float(), 'f', -1, 64))
+	case dataVal.Type() == timeType:
+		val.SetString(data.(time.Time).Format(timeFormat))
 	default:
 		return fmt.Errorf("expected type '%s', got unconvertible type '%s'", val.Type(), dataVal.Type())
 	}
@@ -274,17 +379,28 @@ func (d *decoder) decodeMap(data interface{}, val reflect.Value) error {
 			valMap.SetMapIndex(currentKey, currentValue)
 		}
 	case dataVal.Kind() == reflect.Struct:
-		dataType := reflect.TypeOf(data)
+		dataType := dataVal.Type()
 		for i, l := 0, dataType.NumField(); i < l; i++ {
 			field := dataType.Field(i)
-			fieldValue := dataVal.FieldByName(field.Name).Interface()
-			if reflect.DeepEqual(fieldValue, reflect.Zero(field.Type).Interface()) {
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := parseFieldTag(field)
+			if tag.ignore {
+				continue
+			}
+			fieldValue := dataVal.Field(i).Interface()
+			if isNullable(field.Type) {
+				if isNull(fieldValue) && (tag.omitEmpty || !d.keepNull) && !d.keepZero(field, tag) {
+					continue
+				}
+			} else if !d.keepZero(field, tag) && reflect.DeepEqual(fieldValue, reflect.Zero(field.Type).Interface()) {
 				// fieldValue = reflect.Zero(field.Type).Interface()
 				continue
 			}
 			if field.Type.String() == "time.Time" && valElemType.Kind() == reflect.String {
 				if !reflect.DeepEqual(reflect.Zero(field.Type).Interface(), fieldValue) {
-					val.SetMapIndex(reflect.ValueOf(field.Name), reflect.ValueOf(fieldValue.(time.Time).Format(time.RFC3339Nano)))
+					valMap.SetMapIndex(reflect.ValueOf(tag.name), reflect.ValueOf(fieldValue.(time.Time).Format(time.RFC3339Nano)))
 				}
 				continue
 			}
@@ -292,7 +408,7 @@ func (d *decoder) decodeMap(data interface{}, val reflect.Value) error {
 			if err := d.decode(fieldValue, currentValue); err != nil {
 				return err
 			}
-			valMap.SetMapIndex(reflect.ValueOf(field.Name), currentValue)
+			valMap.SetMapIndex(reflect.ValueOf(tag.name), currentValue)
 		}
 	default:
 		return fmt.Errorf("expected type '%s', got unconvertible type '%s'", val.Type(), dataVal.Type())
@@ -336,7 +452,11 @@ func (d *decoder) decodeStruct(data interface{}, val reflect.Value) error {
 		return fmt.Errorf("Expected a map, got '%s'", kind.String())
 	}
 	for i, l := 0, valType.NumField(); i < l; i++ {
-		fieldName := valType.Field(i).Name
+		tag := parseFieldTag(valType.Field(i))
+		if tag.ignore {
+			continue
+		}
+		fieldName := tag.name
 		rawMapKey := reflect.ValueOf(fieldName)
 		rawMapValue := dataVal.MapIndex(rawMapKey)
 		if !rawMapValue.IsValid() {
@@ -370,18 +490,11 @@ func (d *decoder) decodeStruct(data interface{}, val reflect.Value) error {
 func (d *decoder) decodeTime(data interface{}, val reflect.Value) error {
 	var tVal time.Time
 	if v, ok := data.(string); ok && v != "" {
-		var exist bool
-		for i, l := 0, len(TimeFormats); i < l; i++ {
-			t, err := time.Parse(TimeFormats[i], v)
-			if err == nil {
-				tVal = t
-				exist = true
-				break
-			}
-		}
-		if !exist {
-			return fmt.Errorf("Unknown time format.")
+		t, err := parseTime(v)
+		if err != nil {
+			return err
 		}
+		tVal = t
 	} else if v, ok := data.(time.Time); ok {
 		tVal = v
 	} else {
@@ -391,6 +504,16 @@ func (d *decoder) decodeTime(data interface{}, val reflect.Value) error {
 	return nil
 }
 
+// parseTime 使用TimeFormats解析时间字符串
+func parseTime(s string) (time.Time, error) {
+	for i, l := 0, len(TimeFormats); i < l; i++ {
+		if t, err := time.Parse(TimeFormats[i], s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown time format.")
+}
+
 func (d *decoder) decodeBasic(data interface{}, val reflect.Value) error {
 	dataVal := reflect.ValueOf(data)
 	dataValType := dataVal.Type()
@@ -401,3 +524,110 @@ func (d *decoder) decodeBasic(data interface{}, val reflect.Value) error {
 	val.Set(dataVal)
 	return nil
 }
+
+// decodePtr 解析到指针，数据为nil时指针为nil
+func (d *decoder) decodePtr(data interface{}, val reflect.Value) error {
+	elem := reflect.New(val.Type().Elem())
+	if err := d.decode(data, elem.Elem()); err != nil {
+		return err
+	}
+	val.Set(elem)
+	return nil
+}
+
+// decodeScanner 使用sql.Scanner解析数据（例如：sql.NullString,sql.NullInt64,sql.NullTime）
+func (d *decoder) decodeScanner(data interface{}, val reflect.Value) error {
+	if data != nil && reflect.TypeOf(data) == val.Type() {
+		val.Set(reflect.ValueOf(data))
+		return nil
+	}
+	if v, ok := data.(driver.Valuer); ok {
+		var err error
+		if data, err = v.Value(); err != nil {
+			return err
+		}
+	}
+	// 时间类型(例如sql.NullTime)的字符串值先解析为时间
+	if isTimeScanner(val.Type()) {
+		var s string
+		switch v := data.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		}
+		if s != "" {
+			t, err := parseTime(s)
+			if err != nil {
+				return err
+			}
+			data = t
+		}
+	}
+	return val.Addr().Interface().(sql.Scanner).Scan(data)
+}
+
+// isTimeScanner 是否为包含time.Time类型Time字段的结构体(例如sql.NullTime)
+func isTimeScanner(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	field, ok := t.FieldByName("Time")
+	return ok && field.Type == timeType
+}
+
+// isNullable 字段类型可以表示SQL NULL（指针或实现了driver.Valuer）
+func isNullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr || t.Implements(valuerType)
+}
+
+func isNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		return v.IsNil()
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
+
+// TagName 结构体字段标签名称
+// 格式：`dal:"column_name,omitempty"`，`dal:"-"`表示忽略该字段
+const TagName = "dal"
+
+type fieldTag struct {
+	name      string
+	omitEmpty bool
+	ignore    bool
+}
+
+// parseFieldTag 解析字段标签，未指定列名时使用字段名称
+func parseFieldTag(field reflect.StructField) (tag fieldTag) {
+	tag.name = field.Name
+	value, ok := field.Tag.Lookup(TagName)
+	if !ok {
+		return
+	}
+	if value == "-" {
+		tag.ignore = true
+		return
+	}
+	items := strings.Split(value, ",")
+	if name := strings.TrimSpace(items[0]); name != "" {
+		tag.name = name
+	}
+	for _, item := range items[1:] {
+		switch strings.TrimSpace(item) {
+		case "omitempty":
+			tag.omitEmpty = true
+		case "-":
+			tag.ignore = true
+		}
+	}
+	return
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"database/sql"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -72,3 +74,155 @@ func TestDecodeToStructSlice(t *testing.T) {
 	}
 	t.Log("User List:", userData)
 }
+
+func TestDecodeTypedMapToStruct(t *testing.T) {
+	birthday := time.Date(1990, 10, 13, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"ID":       int64(1),
+		"Name":     []byte("Lyric"),
+		"Age":      []byte("26"),
+		"Birthday": birthday,
+		"Memo":     nil,
+	}
+	var user TestUser
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Name != "Lyric" || user.Age != 26 || !user.Birthday.Equal(birthday) || user.Memo != "" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	var strData map[string]string
+	if err := NewDecoder(data).Decode(&strData); err != nil {
+		t.Fatal(err)
+	}
+	if strData["Birthday"] != "1990-10-13 00:00:00" || strData["Name"] != "Lyric" {
+		t.Errorf("unexpected map: %v", strData)
+	}
+}
+
+type TestNullUser struct {
+	ID       int64
+	Name     *string
+	Age      sql.NullInt64
+	Birthday sql.NullTime
+	Memo     sql.NullString
+}
+
+func TestDecodeNullToStruct(t *testing.T) {
+	birthday := time.Date(1990, 10, 13, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"ID":       int64(1),
+		"Name":     "Lyric",
+		"Age":      int64(26),
+		"Birthday": birthday,
+		"Memo":     nil,
+	}
+	var user TestNullUser
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name == nil || *user.Name != "Lyric" || user.Age.Int64 != 26 || !user.Birthday.Time.Equal(birthday) || user.Memo.Valid {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	data["Name"] = nil
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != nil {
+		t.Errorf("expected nil name, got %v", *user.Name)
+	}
+}
+
+func TestDecodeStringToNullTime(t *testing.T) {
+	data := map[string]interface{}{
+		"ID":       int64(1),
+		"Birthday": "1990-10-13 08:30:00",
+	}
+	var user TestNullUser
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	expect := time.Date(1990, 10, 13, 8, 30, 0, 0, time.UTC)
+	if !user.Birthday.Valid || !user.Birthday.Time.Equal(expect) {
+		t.Errorf("unexpected birthday: %+v", user.Birthday)
+	}
+	data["Birthday"] = []byte("1990-10-13")
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if !user.Birthday.Time.Equal(time.Date(1990, 10, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected birthday: %+v", user.Birthday)
+	}
+	data["Birthday"] = "not a time"
+	if err := NewDecoder(data).Decode(&user); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestEncodeNullFromStruct(t *testing.T) {
+	name := "Lyric"
+	user := TestNullUser{ID: 1, Name: &name, Age: sql.NullInt64{Int64: 0, Valid: true}}
+	var data map[string]interface{}
+	if err := NewDecoder(user, KeepNull()).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{"ID": int64(1), "Name": "Lyric", "Age": int64(0), "Birthday": nil, "Memo": nil}
+	if !reflect.DeepEqual(data, expect) {
+		t.Errorf("unexpected map: %#v", data)
+	}
+	data = nil
+	if err := NewDecoder(user).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["Memo"]; ok || len(data) != 3 {
+		t.Errorf("unexpected map without null: %#v", data)
+	}
+}
+
+type TestTagUser struct {
+	ID      int64   `dal:"id"`
+	StuCode string  `dal:"stu_code"`
+	Memo    *string `dal:"memo,omitempty"`
+	Age     int     `dal:"-"`
+	Name    string
+}
+
+func TestDecodeTag(t *testing.T) {
+	data := map[string]interface{}{"id": int64(1), "STU_CODE": "S001", "Age": 26, "Name": "Lyric"}
+	var user TestTagUser
+	if err := NewDecoder(data).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.StuCode != "S001" || user.Age != 0 || user.Name != "Lyric" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	var fields map[string]interface{}
+	user.Age = 26
+	if err := NewDecoder(user, KeepNull()).Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{"id": int64(1), "stu_code": "S001", "Name": "Lyric"}
+	if !reflect.DeepEqual(fields, expect) {
+		t.Errorf("unexpected map: %#v", fields)
+	}
+}
+
+func TestEncodeIncludeZero(t *testing.T) {
+	user := TestUser{ID: 1, Name: "Lyric"}
+	var fields map[string]interface{}
+	if err := NewDecoder(user, IncludeZero("age", "Memo")).Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{"ID": int64(1), "Name": "Lyric", "Age": int64(0), "Memo": ""}
+	if !reflect.DeepEqual(fields, expect) {
+		t.Errorf("unexpected map: %#v", fields)
+	}
+	fields = nil
+	if err := NewDecoder(TestTagUser{ID: 1}, IncludeZero()).Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	expect = map[string]interface{}{"id": int64(1), "stu_code": "", "Name": ""}
+	if !reflect.DeepEqual(fields, expect) {
+		t.Errorf("unexpected map with tags: %#v", fields)
+	}
+}
@@ -0,0 +1,45 @@
+package dal
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TimeFormat 查询结果中的时间转换为字符串的格式
+const TimeFormat = "2006-01-02 15:04:05.999999"
+
+// FormatValue 将查询结果中的值转换为字符串(NULL转换为空字符串，时间使用TimeFormat格式)
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(TimeFormat)
+	}
+	return fmt.Sprint(value)
+}
+
+// ExpandValues 如果只有一个切片类型的参数([]byte除外)，则展开切片元素
+// 例如：In("ID", []int{1, 2}) 与 In("ID", 1, 2) 等价
+func ExpandValues(values []interface{}) []interface{} {
+	if len(values) != 1 || values[0] == nil {
+		return values
+	}
+	if _, ok := values[0].([]byte); ok {
+		return values
+	}
+	rv := reflect.ValueOf(values[0])
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return values
+	}
+	expanded := make([]interface{}, rv.Len())
+	for i := range expanded {
+		expanded[i] = rv.Index(i).Interface()
+	}
+	return expanded
+}